@@ -2,20 +2,98 @@ package api
 
 import (
 	"context"
+	"time"
 
+	"github.com/renix-codex/ingestor/internal/ingest"
 	"github.com/renix-codex/ingestor/internal/models"
 )
 
-// IngestOnce triggers a single ingestion run.
-func (a *API) IngestOnce(ctx context.Context) (int, error) {
-	return a.ing.IngestOnce(ctx)
+// IngestOnce starts an ingestion run of source, or of every idle source when
+// source is empty, in the background and returns the started runs. It fails
+// with ingest.ErrRunInProgress if the source is already being ingested.
+func (a *API) IngestOnce(ctx context.Context, source string) ([]models.Run, error) {
+	if source == "" {
+		return a.ing.StartAll(ctx)
+	}
+	run, err := a.ing.StartSource(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Run{run}, nil
 }
 
-// QueryByUser returns enriched posts for a user.
-func (a *API) QueryByUser(ctx context.Context, userID int) ([]models.EnrichedPost, error) {
-	return a.ing.QueryByUser(ctx, userID)
+// IngestSource runs a single ingestion of one source and reports how the
+// fetched posts were absorbed.
+func (a *API) IngestSource(ctx context.Context, source string) (models.UpsertResult, error) {
+	return a.ing.IngestSource(ctx, source)
 }
 
-func (a *API) QueryRecent(ctx context.Context, limit, offset int) ([]models.EnrichedPost, error) {
-	return a.ing.QueryRecent(ctx, limit, offset)
+// Push ingests posts delivered to a push source and returns the finished run
+// and whether it was replayed for a repeated idempotency key.
+func (a *API) Push(ctx context.Context, req ingest.PushRequest) (models.Run, bool, error) {
+	return a.ing.Push(ctx, req)
+}
+
+// Sources returns the ingestion status of every configured source.
+func (a *API) Sources() []models.SourceStatus {
+	return a.ing.Sources()
+}
+
+// GetPost returns the post (userID, id), tombstoned or not; ingest.ErrNotFound
+// if there is none.
+func (a *API) GetPost(ctx context.Context, userID, id int) (models.EnrichedPost, error) {
+	return a.ing.GetPost(ctx, models.PostKey{UserID: userID, ID: id})
+}
+
+// QueryPosts returns a page of the posts matching q and the cursor of the
+// next page ("" on the last page). A non-empty cursor resumes a previous
+// listing and takes precedence over q.Offset; an unparseable one, or one
+// issued for another sort, fails with ingest.ErrInvalidCursor.
+func (a *API) QueryPosts(ctx context.Context, q ingest.PostQuery, cursor string) ([]models.EnrichedPost, string, error) {
+	if cursor != "" {
+		c, err := ingest.ParseCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		q.After = &c
+	}
+	page, err := a.ing.QueryPosts(ctx, q)
+	if err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if page.Next != nil {
+		next = page.Next.String()
+	}
+	return page.Items, next, nil
+}
+
+// ExportPosts hands every post matching q to fn as it is read from the
+// store; cursor, if non-empty, resumes after a post of a previous listing.
+// A positive q.Limit caps the number of posts.
+func (a *API) ExportPosts(ctx context.Context, q ingest.PostQuery, cursor string, fn func(models.EnrichedPost) error) error {
+	if cursor != "" {
+		c, err := ingest.ParseCursor(cursor)
+		if err != nil {
+			return err
+		}
+		q.After = &c
+	}
+	return a.ing.ExportPosts(ctx, q, fn)
+}
+
+// SearchPosts runs a full-text search over the posts matching the filters
+// of q, best match first.
+func (a *API) SearchPosts(ctx context.Context, q ingest.SearchQuery) ([]models.SearchHit, error) {
+	return a.ing.SearchPosts(ctx, q)
+}
+
+// ListVersions returns every recorded revision of a post, oldest first.
+func (a *API) ListVersions(ctx context.Context, userID, id int) ([]models.PostVersion, error) {
+	return a.ing.ListVersions(ctx, userID, id)
+}
+
+// QueryAsOf returns the posts as they were at time at, optionally for one user (userID > 0).
+func (a *API) QueryAsOf(ctx context.Context, at time.Time, userID, limit, offset int) ([]models.PostVersion, error) {
+	return a.ing.QueryAsOf(ctx, ingest.AsOfQuery{At: at, UserID: userID, Limit: limit, Offset: offset})
 }
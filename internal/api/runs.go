@@ -0,0 +1,18 @@
+package api
+
+import (
+	"context"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// ListRuns returns recorded ingestion runs, newest first, optionally for one source.
+func (a *API) ListRuns(ctx context.Context, source string, limit, offset int) ([]models.Run, error) {
+	return a.ing.ListRuns(ctx, ingest.RunQuery{Source: source, Limit: limit, Offset: offset})
+}
+
+// GetRun returns a single ingestion run; ingest.ErrNotFound if there is none.
+func (a *API) GetRun(ctx context.Context, id int64) (models.Run, error) {
+	return a.ing.GetRun(ctx, id)
+}
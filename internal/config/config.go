@@ -3,18 +3,59 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	// HTTP server
 	ListenAddr  string        // e.g. ":8080"
-	HTTPTimeout time.Duration // e.g. 10s (for upstream API)
+	HTTPTimeout time.Duration // e.g. 10s, until upstream response headers; the run deadline bounds the body
+
+	// Admin API
+	AdminTokenFile string // file holding the bearer token for /admin endpoints; unset disables them
+
+	// Source registry; when set, SOURCE_* variables only provide defaults
+	SourcesFile string // e.g. "/etc/ingestor/sources.json"
 
 	// Upstream source
 	SourceURL  string // e.g. https://jsonplaceholder.typicode.com/posts
 	SourceName string // e.g. "placeholder_api"
 
+	SourceAuth AuthConfig // credentials for the upstream, see AuthConfig
+
+	SourceConditionalGET bool // send If-None-Match/If-Modified-Since from the last stored response
+
+	SourceReconcile         bool    // treat every fetch as a full snapshot and tombstone missing posts
+	SourceMaxDeleteFraction float64 // e.g. 0.1, abort reconciliation that would delete more
+	SearchLanguage          string  // text search configuration of posts, e.g. "english" or "simple"
+	PushMaxBodyBytes        int64   // e.g. 10 MiB, largest delivery accepted by POST /ingest/{source}
+
+	SourceMaxBodyBytes int64 // e.g. 268435456 (256 MiB); 0 disables the limit
+	IngestBatchSize    int   // e.g. 500, posts decoded and upserted per batch
+
+	// Upstream retries; SourceRetryMaxAttempts <= 1 disables retrying
+	SourceRetryMaxAttempts int           // e.g. 4, including the first attempt
+	SourceRetryBaseDelay   time.Duration // e.g. 500ms, doubled after each attempt
+	SourceRetryMaxDelay    time.Duration // e.g. 30s, cap on a single wait
+	SourceRetryMaxElapsed  time.Duration // e.g. 2m, cap on the total time spent retrying
+
+	// Upstream pagination; an empty mode fetches the source in a single request
+	SourcePagination string // "page", "offset", "cursor" or "link"
+	SourcePageSize   int    // e.g. 100
+	SourceMaxPages   int    // e.g. 1000, safety cap per run
+	SourcePageParam  string // override for the page/offset/cursor query parameter
+	SourceSizeParam  string // override for the page size query parameter
+	SourceItemsPath  string // e.g. "data", when pages are JSON objects
+	SourceCursorPath string // e.g. "meta.next_cursor", for cursor mode
+
+	// Ingestion scheduling
+	IngestSchedule string        // e.g. "@every 15m" or "*/10 * * * *"
+	IngestJitter   time.Duration // e.g. 30s, random delay added to each run
+	IngestTimeout  time.Duration // e.g. 30s, deadline for a single run
+	IngestOnStart  bool          // run once at startup before the first tick
+
 	// Postgres (explicit pieces)
 	PGHost     string // e.g. "localhost" or "postgres" when running in compose
 	PGPort     int    // e.g. 5432
@@ -22,6 +63,47 @@ type Config struct {
 	PGPassword string // e.g. "app"
 	PGDatabase string // e.g. "ingestor"
 	PGSSLMode  string // e.g. "disable" locally, "require" in cloud
+
+	MigrateOnStart bool // apply pending schema migrations at startup
+
+	PGUpsertMode string // "atomic" (all or nothing) or "per_row" (commit good rows, report rejected ones)
+
+	// COPY bulk load path for large upserts
+	PGBulkThreshold int // defaults to IngestBatchSize, so full streaming batches take it; negative disables it
+	PGBulkChunkSize int // e.g. 10000 posts per COPY, and per transaction in per_row mode
+}
+
+// AuthConfig describes how to authenticate against an upstream. Secrets are
+// only ever read from files (e.g. mounted Docker/Kubernetes secrets), never
+// taken from plain environment variables.
+type AuthConfig struct {
+	Type string `json:"type"` // "", "bearer", "basic", "apikey" or "oauth2"
+
+	TokenFile string `json:"token_file"` // bearer: file holding the token
+
+	Username     string `json:"username"`      // basic
+	PasswordFile string `json:"password_file"` // basic: file holding the password
+
+	APIKeyName string `json:"api_key_name"` // apikey: header or query parameter name, e.g. "X-API-Key"
+	APIKeyIn   string `json:"api_key_in"`   // apikey: "header" (default) or "query"
+	APIKeyFile string `json:"api_key_file"` // apikey: file holding the key
+
+	TokenURL         string   `json:"token_url"`          // oauth2: token endpoint
+	ClientID         string   `json:"client_id"`          // oauth2
+	ClientSecretFile string   `json:"client_secret_file"` // oauth2: file holding the client secret
+	Scopes           []string `json:"scopes"`             // oauth2: requested scopes
+}
+
+// ReadSecret returns the contents of a secret file without surrounding whitespace.
+func ReadSecret(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("secret file not configured")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
 }
 
 // BuildDSN composes a keyword/value DSN compatible with pgxpool.
@@ -43,9 +125,51 @@ func FromEnv() Config {
 		c.HTTPTimeout = 10 * time.Second
 	}
 
+	c.AdminTokenFile = os.Getenv("ADMIN_TOKEN_FILE")
+	c.SourcesFile = os.Getenv("SOURCES_FILE")
 	c.SourceURL = getenv("SOURCE_URL", "https://jsonplaceholder.typicode.com/posts")
 	c.SourceName = getenv("SOURCE_NAME", "placeholder_api")
 
+	c.SourceAuth = AuthConfig{
+		Type:             os.Getenv("SOURCE_AUTH"),
+		TokenFile:        os.Getenv("SOURCE_AUTH_TOKEN_FILE"),
+		Username:         os.Getenv("SOURCE_AUTH_USERNAME"),
+		PasswordFile:     os.Getenv("SOURCE_AUTH_PASSWORD_FILE"),
+		APIKeyName:       os.Getenv("SOURCE_AUTH_API_KEY_NAME"),
+		APIKeyIn:         os.Getenv("SOURCE_AUTH_API_KEY_IN"),
+		APIKeyFile:       os.Getenv("SOURCE_AUTH_API_KEY_FILE"),
+		TokenURL:         os.Getenv("SOURCE_AUTH_TOKEN_URL"),
+		ClientID:         os.Getenv("SOURCE_AUTH_CLIENT_ID"),
+		ClientSecretFile: os.Getenv("SOURCE_AUTH_CLIENT_SECRET_FILE"),
+		Scopes:           getenvlist("SOURCE_AUTH_SCOPES"),
+	}
+
+	c.SourceConditionalGET = getenvb("SOURCE_CONDITIONAL_GET", true)
+	c.SourceReconcile = getenvb("SOURCE_RECONCILE", false)
+	c.SourceMaxDeleteFraction = getenvf("SOURCE_MAX_DELETE_FRACTION", 0.1)
+	c.SearchLanguage = getenv("SEARCH_LANGUAGE", "english")
+	c.PushMaxBodyBytes = int64(getenvi("PUSH_MAX_BODY_BYTES", 10<<20))
+	c.SourceMaxBodyBytes = int64(getenvi("SOURCE_MAX_BODY_BYTES", 256<<20))
+	c.IngestBatchSize = getenvi("INGEST_BATCH_SIZE", 500)
+
+	c.SourceRetryMaxAttempts = getenvi("SOURCE_RETRY_MAX_ATTEMPTS", 4)
+	c.SourceRetryBaseDelay = getenvd("SOURCE_RETRY_BASE_DELAY", 500*time.Millisecond)
+	c.SourceRetryMaxDelay = getenvd("SOURCE_RETRY_MAX_DELAY", 30*time.Second)
+	c.SourceRetryMaxElapsed = getenvd("SOURCE_RETRY_MAX_ELAPSED", 2*time.Minute)
+
+	c.SourcePagination = os.Getenv("SOURCE_PAGINATION")
+	c.SourcePageSize = getenvi("SOURCE_PAGE_SIZE", 0)
+	c.SourceMaxPages = getenvi("SOURCE_MAX_PAGES", 1000)
+	c.SourcePageParam = os.Getenv("SOURCE_PAGE_PARAM")
+	c.SourceSizeParam = os.Getenv("SOURCE_SIZE_PARAM")
+	c.SourceItemsPath = os.Getenv("SOURCE_ITEMS_PATH")
+	c.SourceCursorPath = os.Getenv("SOURCE_CURSOR_PATH")
+
+	c.IngestSchedule = getenv("INGEST_SCHEDULE", "@every 15m")
+	c.IngestJitter = getenvd("INGEST_JITTER", 30*time.Second)
+	c.IngestTimeout = getenvd("INGEST_TIMEOUT", 30*time.Second)
+	c.IngestOnStart = getenvb("INGEST_ON_START", true)
+
 	// Postgres pieces
 	c.PGHost = getenv("PG_HOST", "postgres")
 	c.PGPort = getenvi("PG_PORT", 5432)
@@ -53,6 +177,10 @@ func FromEnv() Config {
 	c.PGPassword = getenv("PG_PASSWORD", "app")
 	c.PGDatabase = getenv("PG_DATABASE", "ingestor")
 	c.PGSSLMode = getenv("PG_SSLMODE", "disable")
+	c.MigrateOnStart = getenvb("MIGRATE_ON_START", true)
+	c.PGUpsertMode = getenv("PG_UPSERT_MODE", "atomic")
+	c.PGBulkThreshold = getenvi("PG_BULK_THRESHOLD", c.IngestBatchSize)
+	c.PGBulkChunkSize = getenvi("PG_BULK_CHUNK_SIZE", 10000)
 
 	return c
 }
@@ -74,3 +202,41 @@ func getenvi(k string, def int) int {
 	}
 	return def
 }
+
+func getenvd(k string, def time.Duration) time.Duration {
+	if v := os.Getenv(k); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+func getenvf(k string, def float64) float64 {
+	if v := os.Getenv(k); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+func getenvb(k string, def bool) bool {
+	if v := os.Getenv(k); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// getenvlist splits a comma-separated variable, dropping empty items.
+func getenvlist(k string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(k), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
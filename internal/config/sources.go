@@ -0,0 +1,314 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Source is one entry of the source registry: an upstream feed with its own
+// collector, schedule and credentials. Zero fields, and nil pointers for
+// settings where zero is meaningful, inherit the process-wide defaults from
+// Config (see LoadSources).
+type Source struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Type string `json:"type"` // collector type: "http" (default), "paginated", "push", "file" or "dir"
+
+	Schedule    string    `json:"schedule"`     // e.g. "@every 15m" or "*/10 * * * *"
+	Jitter      *Duration `json:"jitter"`       // e.g. "30s"; "0s" for none
+	Timeout     *Duration `json:"timeout"`      // deadline for a single run, e.g. "30s"; "0s" for none
+	HTTPTimeout Duration  `json:"http_timeout"` // until upstream response headers, e.g. "10s"
+	RunOnStart  *bool     `json:"run_on_start"`
+
+	Auth           AuthConfig       `json:"auth"`
+	Pagination     PaginationConfig `json:"pagination"`
+	Mapping        MappingConfig    `json:"mapping"`
+	Retry          RetryConfig      `json:"retry"`
+	ConditionalGET *bool            `json:"conditional_get"`
+	MaxBodyBytes   int64            `json:"max_body_bytes"`
+	BatchSize      int              `json:"batch_size"`
+
+	// Full-snapshot reconciliation: posts missing from a successful fetch
+	// are tombstoned, unless more than MaxDeleteFraction of them would be.
+	Reconcile         *bool    `json:"reconcile"`
+	MaxDeleteFraction *float64 `json:"max_delete_fraction"` // 0 allows no deletions
+
+	// Push configures a "push" source, which producers POST posts to
+	// instead of being polled.
+	Push PushConfig `json:"push"`
+
+	// File configures a "file" source, which reads a local file, or a
+	// "dir" source, which ingests the files dropped into a directory.
+	File FileConfig `json:"file"`
+
+	// Postgres text search configuration the source's posts are indexed
+	// with for full-text search, e.g. "english", "german" or "simple".
+	SearchLanguage string `json:"search_language"`
+}
+
+// PaginationConfig mirrors ingest.Pagination; an empty Mode means the
+// source is fetched in a single request.
+type PaginationConfig struct {
+	Mode       string `json:"mode"` // "page", "offset", "cursor" or "link"
+	PageSize   int    `json:"page_size"`
+	MaxPages   int    `json:"max_pages"`
+	PageParam  string `json:"page_param"`
+	SizeParam  string `json:"size_param"`
+	ItemsPath  string `json:"items_path"`
+	CursorPath string `json:"cursor_path"`
+}
+
+// MappingConfig mirrors ingest.FieldMapping; it is empty for upstreams
+// serving an array of posts as is.
+type MappingConfig struct {
+	Root   string                     `json:"root"`   // path to the items array, e.g. "data.items"
+	Fields map[string]FieldSpecConfig `json:"fields"` // keyed by post field: userId, id, title, body
+}
+
+// FieldSpecConfig mirrors ingest.FieldSpec. In JSON it is either an object
+// or just the path as a string.
+type FieldSpecConfig struct {
+	Path    string `json:"path"`
+	Default any    `json:"default"`
+}
+
+func (f *FieldSpecConfig) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &f.Path); err == nil {
+		return nil
+	}
+	type plain FieldSpecConfig
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(f))
+}
+
+// PushConfig authenticates and bounds deliveries to a push source.
+type PushConfig struct {
+	// HMACSecretFile holds the shared secret deliveries are signed with
+	// (HMAC-SHA256 of the body); required for push sources.
+	HMACSecretFile string `json:"hmac_secret_file"`
+	MaxBodyBytes   int64  `json:"max_body_bytes"`
+}
+
+// FileConfig mirrors ingest.FileCollector and ingest.DirCollector. Path is
+// the file for "file" sources and the directory for "dir" sources.
+type FileConfig struct {
+	Path    string            `json:"path"`
+	Format  string            `json:"format"`  // "json", "ndjson" or "csv"; empty infers it from the extension
+	Columns map[string]string `json:"columns"` // CSV only: post field -> header, e.g. {"userId": "author_id"}
+
+	// "dir" sources only
+	Pattern   string   `json:"pattern"`    // e.g. "*.csv.gz"
+	DoneDir   string   `json:"done_dir"`   // default <path>/done
+	FailedDir string   `json:"failed_dir"` // default <path>/failed
+	MinAge    Duration `json:"min_age"`    // skip files modified more recently, e.g. "30s"
+}
+
+// RetryConfig mirrors ingest.RetryPolicy; MaxAttempts <= 1 disables retries.
+type RetryConfig struct {
+	MaxAttempts int      `json:"max_attempts"`
+	BaseDelay   Duration `json:"base_delay"`
+	MaxDelay    Duration `json:"max_delay"`
+	MaxElapsed  Duration `json:"max_elapsed"`
+}
+
+// Duration is a time.Duration written as a Go duration string in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadSources returns the source registry. Without SOURCES_FILE it is the
+// single source described by the SOURCE_* variables; otherwise the file is
+// read as a JSON array of Source and every entry inherits unset fields from
+// the process-wide defaults.
+func (c Config) LoadSources() ([]Source, error) {
+	if c.SourcesFile == "" {
+		s := c.envSource()
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("sources: %w", err)
+		}
+		return []Source{s}, nil
+	}
+
+	b, err := os.ReadFile(c.SourcesFile)
+	if err != nil {
+		return nil, fmt.Errorf("sources: %w", err)
+	}
+	// a misspelt field would otherwise silently fall back to its default
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var sources []Source
+	if err := dec.Decode(&sources); err != nil {
+		return nil, fmt.Errorf("sources: %s: %w", c.SourcesFile, err)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("sources: %s defines no sources", c.SourcesFile)
+	}
+
+	seen := map[string]bool{}
+	for i := range sources {
+		s := &sources[i]
+		if s.Name == "" {
+			return nil, fmt.Errorf("sources: entry %d has no name", i)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("sources: duplicate name %q", s.Name)
+		}
+		seen[s.Name] = true
+		c.applyDefaults(s)
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("sources: %s: %w", s.Name, err)
+		}
+	}
+	return sources, nil
+}
+
+func (s Source) validate() error {
+	switch s.Type {
+	case "push":
+		if s.Push.HMACSecretFile == "" {
+			return fmt.Errorf("push source needs push.hmac_secret_file")
+		}
+	case "file", "dir":
+		if s.File.Path == "" {
+			return fmt.Errorf("%s source needs file.path", s.Type)
+		}
+	default:
+		if s.URL == "" {
+			return fmt.Errorf("url is required")
+		}
+	}
+	if f := *s.MaxDeleteFraction; f < 0 || f > 1 {
+		return fmt.Errorf("max_delete_fraction must be between 0 and 1")
+	}
+	if s.Type != "http" && s.Type != "paginated" && (s.Mapping.Root != "" || len(s.Mapping.Fields) > 0) {
+		return fmt.Errorf("mapping is only supported by http and paginated sources")
+	}
+	if s.Type == "paginated" && s.Mapping.Root != "" {
+		return fmt.Errorf("paginated source locates items with pagination.items_path, not mapping.root")
+	}
+	switch s.Type {
+	case "http", "push", "file":
+	case "dir":
+		// every run sees only the newly dropped files, never a full snapshot
+		if *s.Reconcile {
+			return fmt.Errorf("dir source cannot reconcile")
+		}
+	case "paginated":
+		if s.Pagination.Mode == "" {
+			return fmt.Errorf("paginated source needs pagination.mode")
+		}
+	default:
+		return fmt.Errorf("unknown collector type %q", s.Type)
+	}
+	switch s.Pagination.Mode {
+	case "":
+	case "page", "offset", "cursor", "link":
+		if s.Type != "paginated" {
+			return fmt.Errorf("pagination is only supported by paginated sources")
+		}
+	default:
+		return fmt.Errorf("unknown pagination.mode %q, want page, offset, cursor or link", s.Pagination.Mode)
+	}
+	return nil
+}
+
+// envSource describes the single source configured through SOURCE_* variables.
+func (c Config) envSource() Source {
+	s := Source{
+		Name: c.SourceName,
+		URL:  c.SourceURL,
+		Auth: c.SourceAuth,
+		Pagination: PaginationConfig{
+			Mode:       c.SourcePagination,
+			PageSize:   c.SourcePageSize,
+			MaxPages:   c.SourceMaxPages,
+			PageParam:  c.SourcePageParam,
+			SizeParam:  c.SourceSizeParam,
+			ItemsPath:  c.SourceItemsPath,
+			CursorPath: c.SourceCursorPath,
+		},
+	}
+	if s.Pagination.Mode != "" {
+		s.Type = "paginated"
+	}
+	c.applyDefaults(&s)
+	return s
+}
+
+func (c Config) applyDefaults(s *Source) {
+	if s.Type == "" {
+		s.Type = "http"
+	}
+	if s.Reconcile == nil {
+		s.Reconcile = &c.SourceReconcile
+	}
+	if s.MaxDeleteFraction == nil {
+		s.MaxDeleteFraction = &c.SourceMaxDeleteFraction
+	}
+	if s.SearchLanguage == "" {
+		s.SearchLanguage = c.SearchLanguage
+	}
+	if s.Push.MaxBodyBytes == 0 {
+		s.Push.MaxBodyBytes = c.PushMaxBodyBytes
+	}
+	if s.Schedule == "" {
+		s.Schedule = c.IngestSchedule
+	}
+	if s.Jitter == nil {
+		d := Duration(c.IngestJitter)
+		s.Jitter = &d
+	}
+	if s.Timeout == nil {
+		d := Duration(c.IngestTimeout)
+		s.Timeout = &d
+	}
+	if s.HTTPTimeout == 0 {
+		s.HTTPTimeout = Duration(c.HTTPTimeout)
+	}
+	if s.RunOnStart == nil {
+		s.RunOnStart = &c.IngestOnStart
+	}
+	if s.ConditionalGET == nil {
+		s.ConditionalGET = &c.SourceConditionalGET
+	}
+	if s.MaxBodyBytes == 0 {
+		s.MaxBodyBytes = c.SourceMaxBodyBytes
+	}
+	if s.BatchSize == 0 {
+		s.BatchSize = c.IngestBatchSize
+	}
+	if s.Pagination.MaxPages == 0 {
+		s.Pagination.MaxPages = c.SourceMaxPages
+	}
+	if s.Retry.MaxAttempts == 0 {
+		s.Retry.MaxAttempts = c.SourceRetryMaxAttempts
+	}
+	if s.Retry.BaseDelay == 0 {
+		s.Retry.BaseDelay = Duration(c.SourceRetryBaseDelay)
+	}
+	if s.Retry.MaxDelay == 0 {
+		s.Retry.MaxDelay = Duration(c.SourceRetryMaxDelay)
+	}
+	if s.Retry.MaxElapsed == 0 {
+		s.Retry.MaxElapsed = Duration(c.SourceRetryMaxElapsed)
+	}
+}
@@ -0,0 +1,199 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to an outgoing upstream request.
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *http.Request) error
+}
+
+// refresher is implemented by authenticators whose credentials can go stale;
+// Invalidate is called when the upstream answers 401 so the request can be
+// retried once with fresh credentials.
+type refresher interface {
+	Invalidate()
+}
+
+// BearerToken sends a static "Authorization: Bearer" token.
+type BearerToken struct {
+	Token string
+}
+
+func (b BearerToken) Authenticate(_ context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+	return nil
+}
+
+// BasicAuth sends HTTP basic credentials.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (b BasicAuth) Authenticate(_ context.Context, req *http.Request) error {
+	req.SetBasicAuth(b.Username, b.Password)
+	return nil
+}
+
+// APIKeyLocation says where an API key is sent.
+type APIKeyLocation string
+
+const (
+	APIKeyInHeader APIKeyLocation = "header"
+	APIKeyInQuery  APIKeyLocation = "query"
+)
+
+// APIKey sends a key in a named header (default X-API-Key) or query parameter.
+type APIKey struct {
+	Name  string
+	Value string
+	In    APIKeyLocation
+}
+
+func (k APIKey) Authenticate(_ context.Context, req *http.Request) error {
+	switch k.In {
+	case APIKeyInQuery:
+		q := req.URL.Query()
+		q.Set(k.name(), k.Value)
+		req.URL.RawQuery = q.Encode()
+	case APIKeyInHeader, "":
+		req.Header.Set(k.name(), k.Value)
+	default:
+		return fmt.Errorf("auth: unknown api key location %q", k.In)
+	}
+	return nil
+}
+
+func (k APIKey) name() string {
+	if k.Name != "" {
+		return k.Name
+	}
+	if k.In == APIKeyInQuery {
+		return "api_key"
+	}
+	return "X-API-Key"
+}
+
+// secretParam names the query parameter to redact from logged URLs.
+func (k APIKey) secretParam() string {
+	if k.In == APIKeyInQuery {
+		return k.name()
+	}
+	return ""
+}
+
+// OAuth2ClientCredentials obtains bearer tokens with the OAuth2 client
+// credentials grant (RFC 6749 §4.4) and caches them until shortly before
+// they expire.
+type OAuth2ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Client       *http.Client // used for token requests; defaults to a 10s-timeout client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time // zero means unknown: reuse until the upstream rejects it
+	now    func() time.Time
+}
+
+// tokenExpirySkew refreshes tokens this long before their stated expiry.
+const tokenExpirySkew = 30 * time.Second
+
+func (o *OAuth2ClientCredentials) Authenticate(ctx context.Context, req *http.Request) error {
+	tok, err := o.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	return nil
+}
+
+// Token returns a cached access token, fetching a new one if needed.
+func (o *OAuth2ClientCredentials) Token(ctx context.Context) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.clock()
+	if o.token != "" && (o.expiry.IsZero() || now.Before(o.expiry.Add(-tokenExpirySkew))) {
+		return o.token, nil
+	}
+	tok, expiresIn, err := o.fetchToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	o.token = tok
+	o.expiry = time.Time{}
+	if expiresIn > 0 {
+		o.expiry = now.Add(expiresIn)
+	}
+	return o.token, nil
+}
+
+// Invalidate drops the cached token so the next request fetches a new one.
+func (o *OAuth2ClientCredentials) Invalidate() {
+	o.mu.Lock()
+	o.token = ""
+	o.mu.Unlock()
+}
+
+func (o *OAuth2ClientCredentials) clock() time.Time {
+	if o.now != nil {
+		return o.now()
+	}
+	return time.Now()
+}
+
+func (o *OAuth2ClientCredentials) fetchToken(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("oauth2: token request: %w", transportError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", 0, fmt.Errorf("oauth2: token request: %w", newUpstreamError(resp, ""))
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("oauth2: %w: %w", ErrDecode, err)
+	}
+	if body.AccessToken == "" {
+		return "", 0, fmt.Errorf("oauth2: %w: token response has no access_token", ErrDecode)
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("oauth2: unsupported token type %q", body.TokenType)
+	}
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, nil
+}
@@ -0,0 +1,152 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuth_StaticSchemes(t *testing.T) {
+	cases := []struct {
+		name  string
+		auth  Authenticator
+		check func(r *http.Request) bool
+	}{
+		{"bearer", BearerToken{Token: "t0k"}, func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer t0k"
+		}},
+		{"basic", BasicAuth{Username: "u", Password: "p"}, func(r *http.Request) bool {
+			u, p, ok := r.BasicAuth()
+			return ok && u == "u" && p == "p"
+		}},
+		{"apikey header", APIKey{Value: "k"}, func(r *http.Request) bool {
+			return r.Header.Get("X-API-Key") == "k"
+		}},
+		{"apikey query", APIKey{Name: "key", Value: "k", In: APIKeyInQuery}, func(r *http.Request) bool {
+			return r.URL.Query().Get("key") == "k" && r.URL.Query().Get("keep") == "1"
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tc.check(r) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`[]`))
+			}))
+			defer s.Close()
+
+			c := NewHTTPCollector(s.URL+"?keep=1", 2*time.Second)
+			c.Auth = tc.auth
+			if _, err := c.Fetch(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuth_APIKeyRedactedFromErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	c := NewHTTPCollector(s.URL, 2*time.Second)
+	c.Auth = APIKey{Name: "key", Value: "supersecret", In: APIKeyInQuery}
+	_, err := c.Fetch(context.Background())
+	if err == nil || strings.Contains(err.Error(), "supersecret") {
+		t.Fatalf("expected error without the api key, got %v", err)
+	}
+}
+
+// tokenServer issues numbered tokens and counts token requests.
+func tokenServer(t *testing.T, expiresIn int, issued *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" {
+			t.Errorf("bad token request: id=%q grant=%q", id, r.FormValue("grant_type"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("scope") != "read write" {
+			t.Errorf("unexpected scope %q", r.FormValue("scope"))
+		}
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"tok%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+}
+
+func TestAuth_OAuth2CachesAndRefreshes(t *testing.T) {
+	var issued atomic.Int32
+	ts := tokenServer(t, 3600, &issued)
+	defer ts.Close()
+
+	now := time.Date(2025, 8, 17, 10, 0, 0, 0, time.UTC)
+	o := &OAuth2ClientCredentials{
+		TokenURL: ts.URL, ClientID: "client", ClientSecret: "s3cret", Scopes: []string{"read", "write"},
+		now: func() time.Time { return now },
+	}
+
+	for i := 0; i < 3; i++ {
+		tok, err := o.Token(context.Background())
+		if err != nil || tok != "tok1" {
+			t.Fatalf("expected cached tok1, got %q err=%v", tok, err)
+		}
+	}
+	now = now.Add(time.Hour - tokenExpirySkew/2) // inside the refresh skew
+	if tok, _ := o.Token(context.Background()); tok != "tok2" {
+		t.Fatalf("expected refreshed tok2, got %q", tok)
+	}
+	if issued.Load() != 2 {
+		t.Fatalf("expected 2 token requests, got %d", issued.Load())
+	}
+}
+
+func TestAuth_OAuth2RetriesOnceAfter401(t *testing.T) {
+	var issued atomic.Int32
+	ts := tokenServer(t, 0, &issued)
+	defer ts.Close()
+
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("Authorization") != "Bearer tok2" { // first token was revoked
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"userId":1,"id":1,"title":"a","body":"b"}]`))
+	}))
+	defer s.Close()
+
+	c := NewHTTPCollector(s.URL, 2*time.Second)
+	c.Auth = &OAuth2ClientCredentials{TokenURL: ts.URL, ClientID: "client", ClientSecret: "s3cret", Scopes: []string{"read", "write"}}
+	posts, err := c.Fetch(context.Background())
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("expected success after token refresh, got %d posts err=%v", len(posts), err)
+	}
+	if calls.Load() != 2 || issued.Load() != 2 {
+		t.Fatalf("expected 2 upstream calls and 2 tokens, got %d and %d", calls.Load(), issued.Load())
+	}
+}
+
+func TestAuth_OAuth2TokenEndpointError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	o := &OAuth2ClientCredentials{TokenURL: ts.URL, ClientID: "client", ClientSecret: "wrong"}
+	_, err := o.Token(context.Background())
+	var ue *UpstreamError
+	if !errors.As(err, &ue) || ue.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected UpstreamError 401, got %v", err)
+	}
+}
@@ -2,11 +2,12 @@ package ingest
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/renix-codex/ingestor/internal/models"
@@ -17,49 +18,189 @@ type Collector interface {
 }
 
 type HTTPCollector struct {
-	Client    *http.Client
-	SourceURL string
+	Client       *http.Client
+	SourceURL    string
+	MaxBodyBytes int64 // 0 means unlimited
+	BatchSize    int   // posts per Stream batch; 0 means DefaultBatchSize
+
+	// Auth, when set, adds credentials to every request.
+	Auth Authenticator
+
+	// Mapping, when set, locates the items and their fields in bodies of
+	// another shape than an array of posts.
+	Mapping *FieldMapping
+
+	// Validators, when set, makes requests conditional on the ETag and
+	// Last-Modified of the last committed response for Source.
+	Source     string
+	Validators ValidatorStore
+
+	mu      sync.Mutex
+	pending *Validators // validators of the last complete response, not yet committed
 }
 
+// Ensure HTTPCollector implements the StreamCollector and Committer interfaces.
+var (
+	_ StreamCollector = (*HTTPCollector)(nil)
+	_ Committer       = (*HTTPCollector)(nil)
+)
+
 func NewHTTPCollector(sourceURL string, timeout time.Duration) *HTTPCollector {
 	return &HTTPCollector{
 		SourceURL: sourceURL,
-		Client: &http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   5 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				TLSHandshakeTimeout: 5 * time.Second,
-				MaxIdleConns:        100,
-				IdleConnTimeout:     90 * time.Second,
-			},
+		Client:    newHTTPClient(timeout),
+	}
+}
+
+// newHTTPClient returns the client shared by the HTTP-based collectors.
+// timeout bounds the wait for response headers only: a streamed body may
+// take longer and is bounded by the run's context instead.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
 		},
 	}
 }
 
 func (c *HTTPCollector) Fetch(ctx context.Context) ([]models.Post, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.SourceURL, nil)
+	var posts []models.Post
+	err := c.Stream(ctx, func(batch []models.Post) error {
+		posts = append(posts, batch...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	resp, err := c.Client.Do(req)
+	return posts, nil
+}
+
+// Stream decodes the upstream array incrementally and hands it to fn in
+// batches, so memory use is bounded by BatchSize rather than the body size.
+// It returns ErrNotModified if the upstream answers a conditional request
+// with 304.
+func (c *HTTPCollector) Stream(ctx context.Context, fn func([]models.Post) error) error {
+	c.setPending(nil)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.SourceURL, nil)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if c.Validators != nil {
+		v, err := c.Validators.LoadValidators(ctx, c.Source)
+		if err != nil {
+			return err
+		}
+		v.apply(req)
+	}
+
+	resp, err := send(c.Client, c.Auth, req)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return ErrNotModified
+	}
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return nil, errors.New("upstream returned non-2xx")
+	body := limitBody(resp.Body, c.MaxBodyBytes)
+	if c.Mapping != nil {
+		err = c.Mapping.decode(body, c.BatchSize, fn)
+	} else {
+		err = decodeArray(body, c.BatchSize, fn)
+	}
+	if err != nil {
+		return err
 	}
-	body, err := io.ReadAll(resp.Body)
+	v := validatorsFrom(resp.Header)
+	c.setPending(&v)
+	return nil
+}
+
+// Commit persists the validators of the last complete response so the next
+// fetch can be conditional. It is a no-op without a ValidatorStore.
+func (c *HTTPCollector) Commit(ctx context.Context) error {
+	c.mu.Lock()
+	v := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if c.Validators == nil || v == nil {
+		return nil
+	}
+	return c.Validators.SaveValidators(ctx, c.Source, *v)
+}
+
+func (c *HTTPCollector) setPending(v *Validators) {
+	c.mu.Lock()
+	c.pending = v
+	c.mu.Unlock()
+}
+
+// get issues a GET and returns the response if the status is 2xx.
+// The caller must close the body.
+func get(ctx context.Context, client *http.Client, auth Authenticator, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	var posts []models.Post
-	if err := json.Unmarshal(body, &posts); err != nil {
+	return send(client, auth, req)
+}
+
+// send authenticates and performs req, returning the response if the status
+// is 2xx or 304. Any other status is reported as an *UpstreamError. A 401 is
+// retried once with fresh credentials when auth supports refreshing. The
+// caller must close the body.
+func send(client *http.Client, auth Authenticator, req *http.Request) (*http.Response, error) {
+	resp, err := do(client, auth, req)
+	if err != nil {
 		return nil, err
 	}
-	return posts, nil
+	if r, ok := auth.(refresher); ok && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		r.Invalidate()
+		if resp, err = do(client, auth, req.Clone(req.Context())); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, newUpstreamError(resp, secretParamOf(auth))
+	}
+	return resp, nil
+}
+
+func do(client *http.Client, auth Authenticator, req *http.Request) (*http.Response, error) {
+	if auth != nil {
+		if err := auth.Authenticate(req.Context(), req); err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) && req.URL != nil {
+			ue.URL = redactURL(req.URL, secretParamOf(auth))
+		}
+		return nil, transportError(err)
+	}
+	return resp, nil
+}
+
+// secretParamOf returns the query parameter auth puts a secret in, if any.
+func secretParamOf(auth Authenticator) string {
+	if k, ok := auth.(interface{ secretParam() string }); ok {
+		return k.secretParam()
+	}
+	return ""
 }
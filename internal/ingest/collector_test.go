@@ -2,8 +2,10 @@ package ingest
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,6 +47,29 @@ func TestCollector_Timeout(t *testing.T) {
 	}
 }
 
+func TestCollector_SlowBody(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"userId":1,"id":1},`))
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond) // longer than the header timeout
+		_, _ = w.Write([]byte(`{"userId":1,"id":2}]`))
+	}))
+	defer s.Close()
+
+	c := NewHTTPCollector(s.URL, 100*time.Millisecond)
+	posts, err := c.Fetch(context.Background())
+	if err != nil || len(posts) != 2 {
+		t.Fatalf("expected the whole body, got %d posts, %v", len(posts), err)
+	}
+
+	// the run's deadline still bounds the body
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.Fetch(ctx); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
+
 func TestCollector_InvalidStatus(t *testing.T) {
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		http.Error(w, "upstream err", http.StatusBadGateway)
@@ -70,3 +95,127 @@ func TestCollector_InvalidJSON(t *testing.T) {
 	}
 	_ = models.Post{} // keep import if your Fetch signature returns []models.Post
 }
+
+func TestCollector_StreamBatches(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(pageOf(1, 8)))
+	}))
+	defer s.Close()
+
+	c := NewHTTPCollector(s.URL, 2*time.Second)
+	c.BatchSize = 3
+	var sizes []int
+	err := c.Stream(context.Background(), func(batch []models.Post) error {
+		sizes = append(sizes, len(batch))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+		t.Fatalf("unexpected batch sizes %v", sizes)
+	}
+}
+
+func TestCollector_StreamStopsOnCallbackError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(pageOf(1, 8)))
+	}))
+	defer s.Close()
+
+	c := NewHTTPCollector(s.URL, 2*time.Second)
+	c.BatchSize = 2
+	calls := 0
+	boom := errors.New("boom")
+	err := c.Stream(context.Background(), func([]models.Post) error {
+		calls++
+		return boom
+	})
+	if !errors.Is(err, boom) || calls != 1 {
+		t.Fatalf("expected callback error after 1 call, got err=%v calls=%d", err, calls)
+	}
+}
+
+func TestCollector_MaxBodyBytes(t *testing.T) {
+	body := pageOf(1, 50)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer s.Close()
+
+	c := NewHTTPCollector(s.URL, 2*time.Second)
+	c.MaxBodyBytes = int64(len(body) / 2)
+	if _, err := c.Fetch(context.Background()); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected ErrBodyTooLarge, got %v", err)
+	}
+
+	c.MaxBodyBytes = int64(len(body))
+	if posts, err := c.Fetch(context.Background()); err != nil || len(posts) != 49 {
+		t.Fatalf("expected exact-size body to be accepted, got %d posts err=%v", len(posts), err)
+	}
+}
+
+func TestCollector_UpstreamErrorDetails(t *testing.T) {
+	long := strings.Repeat("x", 2*maxErrorBody)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+		http.Error(w, long, http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	u := strings.Replace(s.URL, "http://", "http://user:secret@", 1) + "/posts"
+	c := NewHTTPCollector(u, 2*time.Second)
+	_, err := c.Fetch(context.Background())
+
+	var ue *UpstreamError
+	if !errors.As(err, &ue) {
+		t.Fatalf("expected *UpstreamError, got %T %v", err, err)
+	}
+	if ue.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status %d", ue.StatusCode)
+	}
+	if strings.Contains(ue.URL, "secret") || !strings.HasSuffix(ue.URL, "/posts") {
+		t.Errorf("URL not redacted or wrong: %q", ue.URL)
+	}
+	if ue.Header.Get("X-Request-Id") != "abc" {
+		t.Errorf("headers not captured: %v", ue.Header)
+	}
+	if len(ue.Body) > maxErrorBody+len("…") || !strings.HasSuffix(ue.Body, "…") {
+		t.Errorf("body snippet not truncated: %d bytes", len(ue.Body))
+	}
+}
+
+func TestCollector_TruncatedBody(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"userId":1,"id":1},{"userId":1,"id":`))
+	}))
+	defer s.Close()
+
+	_, err := NewHTTPCollector(s.URL, 2*time.Second).Fetch(context.Background())
+	if !errors.Is(err, ErrTruncated) || errors.Is(err, ErrDecode) {
+		t.Fatalf("expected ErrTruncated and not ErrDecode, got %v", err)
+	}
+	if retry, _ := classifyRetry(err, time.Now()); !retry {
+		t.Fatal("expected a truncated body to be retried")
+	}
+}
+
+func TestCollector_ErrorSentinels(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"userId":"one"}]`))
+	}))
+	defer s.Close()
+
+	if _, err := NewHTTPCollector(s.URL, 2*time.Second).Fetch(context.Background()); !errors.Is(err, ErrDecode) {
+		t.Errorf("expected ErrDecode for wrong field type, got %v", err)
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	if _, err := NewHTTPCollector(slow.URL, 50*time.Millisecond).Fetch(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package ingest
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNotModified is returned by a collector when the upstream reports that
+// nothing changed since the last successful fetch (HTTP 304).
+var ErrNotModified = errors.New("upstream not modified")
+
+// Validators are the HTTP cache validators of the last stored response.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// apply makes req conditional on v.
+func (v Validators) apply(req *http.Request) {
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+func validatorsFrom(h http.Header) Validators {
+	return Validators{ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified")}
+}
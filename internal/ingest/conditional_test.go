@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type memValidators map[string]Validators
+
+func (m memValidators) LoadValidators(_ context.Context, source string) (Validators, error) {
+	return m[source], nil
+}
+
+func (m memValidators) SaveValidators(_ context.Context, source string, v Validators) error {
+	m[source] = v
+	return nil
+}
+
+func TestCollector_ConditionalGet(t *testing.T) {
+	const etag = `"v1"`
+	const lastMod = "Sun, 17 Aug 2025 10:00:00 GMT"
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastMod {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastMod)
+		_, _ = w.Write([]byte(`[{"userId":1,"id":1,"title":"a","body":"b"}]`))
+	}))
+	defer s.Close()
+
+	store := memValidators{}
+	c := NewHTTPCollector(s.URL, 2*time.Second)
+	c.Source, c.Validators = "src", store
+
+	if posts, err := c.Fetch(context.Background()); err != nil || len(posts) != 1 {
+		t.Fatalf("first fetch: posts=%d err=%v", len(posts), err)
+	}
+	// not committed yet: the next fetch must still be unconditional
+	if _, err := c.Fetch(context.Background()); err != nil {
+		t.Fatalf("uncommitted fetch: %v", err)
+	}
+	if err := c.Commit(context.Background()); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if got := store["src"]; got.ETag != etag || got.LastModified != lastMod {
+		t.Fatalf("validators not saved: %+v", got)
+	}
+	if _, err := c.Fetch(context.Background()); !errors.Is(err, ErrNotModified) {
+		t.Fatalf("expected ErrNotModified, got %v", err)
+	}
+	// a 304 leaves nothing to commit
+	store["src"] = Validators{ETag: "sentinel"}
+	if err := c.Commit(context.Background()); err != nil || store["src"].ETag != "sentinel" {
+		t.Fatalf("commit after 304 must be a no-op, got %+v err=%v", store["src"], err)
+	}
+}
@@ -0,0 +1,65 @@
+package ingest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// ErrInvalidCursor is returned for a pagination cursor that was not issued
+// by this service, or not for the query it is used with.
+var ErrInvalidCursor = fmt.Errorf("%w: invalid cursor", ErrInvalidQuery)
+
+// Cursor is a keyset position in a post listing: a page resumes right after
+// the post it names in the order given by Sort, with (id, user_id) breaking
+// ties. Only the key of the sort field is set besides ID and UserID.
+type Cursor struct {
+	Sort       string    `json:"s"`
+	IngestedAt time.Time `json:"t,omitempty"`
+	Text       string    `json:"x,omitempty"` // title or source
+	ID         int       `json:"i"`
+	UserID     int       `json:"u"`
+}
+
+// NewCursor returns the position of p in a listing ordered by sort.
+func NewCursor(sort Sort, p models.EnrichedPost) Cursor {
+	c := Cursor{Sort: sort.String(), ID: p.ID, UserID: p.UserID}
+	switch sort.Field {
+	case SortIngestedAt:
+		c.IngestedAt = p.IngestedAt
+	case SortTitle:
+		c.Text = p.Title
+	case SortSource:
+		c.Text = p.Source
+	}
+	return c
+}
+
+// String encodes c as an opaque URL-safe token.
+func (c Cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseCursor decodes a token produced by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Cursor{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	sort, err := ParseSort(c.Sort)
+	if err != nil || c.Sort == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if sort.Field == SortIngestedAt && c.IngestedAt.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
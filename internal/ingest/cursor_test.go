@@ -0,0 +1,51 @@
+package ingest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	p := models.EnrichedPost{UserID: 7, ID: 42, Title: "hello", IngestedAt: time.Date(2025, 8, 17, 10, 11, 12, 345678000, time.UTC)}
+	for _, sort := range []Sort{DefaultSort, {Field: SortTitle}, {Field: SortID, Desc: true}} {
+		c := NewCursor(sort, p)
+		got, err := ParseCursor(c.String())
+		if err != nil {
+			t.Fatalf("ParseCursor(%s): %v", sort, err)
+		}
+		if !got.IngestedAt.Equal(c.IngestedAt) || got.Text != c.Text || got.Sort != sort.String() ||
+			got.ID != c.ID || got.UserID != c.UserID {
+			t.Fatalf("want %+v, got %+v", c, got)
+		}
+	}
+}
+
+func TestParseCursor_Invalid(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "bm90IGpzb24", Cursor{Sort: "-ingested_at", ID: 1}.String(), Cursor{Sort: "body"}.String()} {
+		if _, err := ParseCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParseCursor(%q): want ErrInvalidCursor, got %v", s, err)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	for in, want := range map[string]Sort{
+		"":             DefaultSort,
+		"title":        {Field: SortTitle},
+		"-user_id":     {Field: SortUserID, Desc: true},
+		"-ingested_at": DefaultSort,
+	} {
+		got, err := ParseSort(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSort(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"body", "-", "doc", "title;drop table posts"} {
+		if _, err := ParseSort(in); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ParseSort(%q): want ErrInvalidQuery, got %v", in, err)
+		}
+	}
+}
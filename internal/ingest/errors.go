@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrDecode marks an upstream body that is not the expected JSON shape.
+	ErrDecode = errors.New("upstream: invalid response body")
+	// ErrTimeout marks an upstream request or body read that timed out.
+	ErrTimeout = errors.New("upstream: timeout")
+	// ErrTruncated marks an upstream body that ended in the middle of the
+	// JSON document. It is a transport failure, retried like a reset
+	// connection.
+	ErrTruncated = errors.New("upstream: truncated response body")
+)
+
+// maxErrorBody bounds the body excerpt kept on an UpstreamError.
+const maxErrorBody = 512
+
+// UpstreamError reports a non-2xx response from an upstream source.
+type UpstreamError struct {
+	StatusCode int
+	URL        string // with credentials redacted
+	Header     http.Header
+	Body       string // leading excerpt of the response body, at most maxErrorBody bytes
+}
+
+func (e *UpstreamError) Error() string {
+	msg := fmt.Sprintf("upstream %s returned %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Body != "" {
+		msg += fmt.Sprintf(": %q", e.Body)
+	}
+	return msg
+}
+
+func (e *UpstreamError) response() (int, http.Header) { return e.StatusCode, e.Header }
+
+// newUpstreamError captures a non-2xx response. It reads at most
+// maxErrorBody bytes of the body and leaves closing it to the caller.
+// secretParam, if set, names a query parameter to redact from the URL.
+func newUpstreamError(resp *http.Response, secretParam string) *UpstreamError {
+	e := &UpstreamError{StatusCode: resp.StatusCode, Header: resp.Header}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.URL = redactURL(resp.Request.URL, secretParam)
+	}
+	buf, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody+1))
+	truncated := len(buf) > maxErrorBody
+	if truncated {
+		buf = buf[:maxErrorBody]
+	}
+	// don't cut a multi-byte rune in half
+	for len(buf) > 0 && !utf8.Valid(buf) {
+		buf = buf[:len(buf)-1]
+	}
+	e.Body = strings.TrimSpace(string(buf))
+	if truncated {
+		e.Body += "…"
+	}
+	return e
+}
+
+// redactURL renders u without any password in its user info and with the
+// value of secretParam (if set) masked.
+func redactURL(u *url.URL, secretParam string) string {
+	if secretParam == "" || !u.Query().Has(secretParam) {
+		return u.Redacted()
+	}
+	c := *u
+	q := c.Query()
+	q.Set(secretParam, "xxxxx")
+	c.RawQuery = q.Encode()
+	return c.Redacted()
+}
+
+// transportError tags timeouts with ErrTimeout and bodies cut off early
+// with ErrTruncated while keeping the original error in the chain.
+func transportError(err error) error {
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %w", ErrTruncated, err)
+	}
+	return err
+}
+
+// decodeError tags JSON shape errors with ErrDecode; read failures that
+// surface through the decoder, including the io.ErrUnexpectedEOF it returns
+// for a body that ends mid-document, are treated as transport errors.
+func decodeError(err error) error {
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &se) || errors.As(err, &te) {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return transportError(err)
+}
@@ -0,0 +1,432 @@
+package ingest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// FileFormat is the encoding of a file read by the file collectors.
+type FileFormat string
+
+const (
+	FormatJSON   FileFormat = "json"   // a JSON array of posts
+	FormatNDJSON FileFormat = "ndjson" // one JSON post per line
+	FormatCSV    FileFormat = "csv"    // a header row, then one post per row
+)
+
+// postFields are the post fields a CSV column can be mapped to.
+var postFields = []string{"userId", "id", "title", "body"}
+
+// FileDecoder describes how the file collectors decode a file. Gzipped files
+// are detected by their content and decompressed transparently.
+type FileDecoder struct {
+	// Format is the file encoding; empty infers it from the extension
+	// (.json, .ndjson or .jsonl, .csv), ignoring a trailing .gz.
+	Format FileFormat
+
+	// Columns maps post fields (userId, id, title, body) to the CSV header
+	// naming them; unmapped fields are looked up by their own name. Headers
+	// match case-insensitively. userId and id are required, title and body
+	// default to empty.
+	Columns map[string]string
+
+	BatchSize int // posts per Stream batch; 0 means DefaultBatchSize
+}
+
+// check reports configuration errors that would make every file fail.
+func (d FileDecoder) check() error {
+	switch d.Format {
+	case "", FormatJSON, FormatNDJSON, FormatCSV:
+	default:
+		return fmt.Errorf("unknown file format %q", d.Format)
+	}
+	for f := range d.Columns {
+		if !slices.Contains(postFields, f) {
+			return fmt.Errorf("columns: unknown post field %q", f)
+		}
+	}
+	return nil
+}
+
+// format returns the format path is decoded as.
+func (d FileDecoder) format(path string) (FileFormat, error) {
+	if d.Format != "" {
+		return d.Format, nil
+	}
+	name := strings.TrimSuffix(strings.ToLower(path), ".gz")
+	switch filepath.Ext(name) {
+	case ".json":
+		return FormatJSON, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	case ".csv":
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("%w: cannot infer the format of %s", ErrDecode, filepath.Base(path))
+}
+
+// decodeFile decodes the file at path, calling fn with batches of posts.
+func (d FileDecoder) decodeFile(ctx context.Context, path string, fn func([]models.Post) error) error {
+	format, err := d.format(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(&ctxReader{ctx: ctx, r: f})
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("%w: gzip: %w", ErrDecode, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	switch format {
+	case FormatNDJSON:
+		err = decodeNDJSON(r, d.BatchSize, fn)
+	case FormatCSV:
+		err = decodeCSV(r, d.Columns, d.BatchSize, fn)
+	default:
+		err = decodeArray(r, d.BatchSize, fn)
+	}
+	// unlike a response body a file is not cut off in transit, so a short
+	// or corrupt file is malformed
+	if !errors.Is(err, ErrDecode) && (errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader)) {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return err
+}
+
+// decodeCSV decodes CSV rows into posts using the header to locate the
+// columns, calling fn with batches of at most batchSize posts.
+func decodeCSV(r io.Reader, columns map[string]string, batchSize int, fn func([]models.Post) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return csvError(err)
+	}
+	header = slices.Clone(header)                       // the reader reuses the record
+	header[0] = strings.TrimPrefix(header[0], "\ufeff") // byte order mark
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+	}
+	idx := make([]int, len(postFields)) // column per postFields entry, -1 if absent
+	for i, f := range postFields {
+		name := f
+		if c, ok := columns[f]; ok {
+			name = c
+		}
+		idx[i] = slices.IndexFunc(header, func(h string) bool { return strings.EqualFold(h, name) })
+		if idx[i] < 0 && i < 2 {
+			return fmt.Errorf("%w: CSV header has no %q column", ErrDecode, name)
+		}
+	}
+
+	batch := make([]models.Post, 0, batchSize)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return csvError(err)
+		}
+		var p models.Post
+		if p.UserID, err = strconv.Atoi(strings.TrimSpace(rec[idx[0]])); err == nil {
+			p.ID, err = strconv.Atoi(strings.TrimSpace(rec[idx[1]]))
+		}
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("%w: line %d: %w", ErrDecode, line, err)
+		}
+		if idx[2] >= 0 {
+			p.Title = rec[idx[2]]
+		}
+		if idx[3] >= 0 {
+			p.Body = rec[idx[3]]
+		}
+		// the row as an object keyed by header
+		row := make(map[string]string, len(header))
+		for j, h := range header {
+			row[h] = rec[j]
+		}
+		if p.Raw, err = marshalCanonical(row); err != nil {
+			return err
+		}
+		batch = append(batch, p)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]models.Post, 0, batchSize)
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// csvError tags malformed CSV with ErrDecode.
+func csvError(err error) error {
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return err
+}
+
+// ctxReader fails reads once ctx is done, so a long file can be abandoned.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// FileCollector reads posts from a local file, for backfills and local
+// testing. The file is read in full on every run.
+type FileCollector struct {
+	Path string
+	FileDecoder
+}
+
+// Ensure FileCollector implements the StreamCollector interface.
+var _ StreamCollector = (*FileCollector)(nil)
+
+func NewFileCollector(path string) *FileCollector {
+	return &FileCollector{Path: path}
+}
+
+func (c *FileCollector) Fetch(ctx context.Context) ([]models.Post, error) {
+	var posts []models.Post
+	err := c.Stream(ctx, func(batch []models.Post) error {
+		posts = append(posts, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// Stream decodes the file incrementally and hands it to fn in batches.
+func (c *FileCollector) Stream(ctx context.Context, fn func([]models.Post) error) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	return c.decodeFile(ctx, c.Path, fn)
+}
+
+// DirCollector ingests the files dropped into a directory. Each run picks up
+// every file matching Pattern, in name order, and moves it to DoneDir once
+// all its posts are stored, or to FailedDir if it cannot be decoded. Polling
+// on the source's schedule stands in for watching the directory.
+type DirCollector struct {
+	Dir     string
+	Pattern string // glob matched against file names; empty matches all
+
+	// DoneDir and FailedDir default to "done" and "failed" inside Dir and
+	// are created as needed. They should be on the same filesystem as Dir,
+	// since files are moved by renaming.
+	DoneDir   string
+	FailedDir string
+
+	// MinAge skips files modified more recently than this, so files still
+	// being written are left for a later run. Writers should preferably
+	// write to a dotfile or a .tmp or .part name, which are always skipped,
+	// and rename it when complete.
+	MinAge time.Duration
+
+	FileDecoder
+
+	now func() time.Time
+}
+
+// Ensure DirCollector implements the StreamCollector interface.
+var _ StreamCollector = (*DirCollector)(nil)
+
+func NewDirCollector(dir string) *DirCollector {
+	return &DirCollector{Dir: dir, now: time.Now}
+}
+
+// Fetch reads every pending file without moving any, since the posts are
+// not stored yet when it returns.
+func (c *DirCollector) Fetch(ctx context.Context) ([]models.Post, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	files, err := c.pending()
+	if err != nil {
+		return nil, err
+	}
+	var posts []models.Post
+	for _, path := range files {
+		err := c.decodeFile(ctx, path, func(batch []models.Post) error {
+			posts = append(posts, batch...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+	}
+	return posts, nil
+}
+
+// Stream hands the posts of every pending file to fn, moving each file to
+// DoneDir as soon as fn accepted all of its batches. A file that fails to
+// decode is moved to FailedDir, possibly after some of its posts were
+// stored, and the remaining files are still processed; the decode errors are
+// returned together at the end. An error from fn, or ctx ending, stops the
+// run and leaves the current file in place for the next one. Without pending
+// files Stream returns ErrNotModified.
+func (c *DirCollector) Stream(ctx context.Context, fn func([]models.Post) error) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	files, err := c.pending()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return ErrNotModified
+	}
+
+	var errs []error
+	for _, path := range files {
+		var fnErr error
+		err := c.decodeFile(ctx, path, func(batch []models.Post) error {
+			fnErr = fn(batch)
+			return fnErr
+		})
+		if err == nil {
+			if err := moveFile(path, c.doneDir()); err != nil {
+				return err
+			}
+			continue
+		}
+		if fnErr != nil || ctx.Err() != nil {
+			return err
+		}
+		err = fmt.Errorf("%s: %w", filepath.Base(path), err)
+		if mvErr := moveFile(path, c.failedDir()); mvErr != nil {
+			return errors.Join(append(errs, err, mvErr)...)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// pending lists the files ready to be ingested, in name order.
+func (c *DirCollector) pending() ([]string, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") ||
+			strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".part") {
+			continue
+		}
+		if c.Pattern != "" {
+			ok, err := filepath.Match(c.Pattern, name)
+			if err != nil {
+				return nil, fmt.Errorf("pattern: %w", err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		if c.MinAge > 0 {
+			info, err := e.Info()
+			if errors.Is(err, os.ErrNotExist) {
+				continue // moved away meanwhile
+			}
+			if err != nil {
+				return nil, err
+			}
+			if now().Sub(info.ModTime()) < c.MinAge {
+				continue
+			}
+		}
+		files = append(files, filepath.Join(c.Dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (c *DirCollector) doneDir() string {
+	if c.DoneDir != "" {
+		return c.DoneDir
+	}
+	return filepath.Join(c.Dir, "done")
+}
+
+func (c *DirCollector) failedDir() string {
+	if c.FailedDir != "" {
+		return c.FailedDir
+	}
+	return filepath.Join(c.Dir, "failed")
+}
+
+// moveFile moves path into dir, creating dir if needed. A file of the same
+// name already there is kept by numbering the new one.
+func moveFile(path, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	base := filepath.Base(path)
+	target := filepath.Join(dir, base)
+	for i := 1; ; i++ {
+		_, err := os.Lstat(target)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		target = filepath.Join(dir, base+"."+strconv.Itoa(i))
+	}
+	return os.Rename(path, target)
+}
@@ -0,0 +1,264 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stripRaw returns posts without their raw upstream objects, for tests
+// about the decoded fields.
+func stripRaw(posts []models.Post) []models.Post {
+	out := make([]models.Post, len(posts))
+	for i, p := range posts {
+		p.Raw = nil
+		out[i] = p
+	}
+	return out
+}
+
+func gzipped(t *testing.T, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestFileCollector_Formats(t *testing.T) {
+	want := []models.Post{{UserID: 1, ID: 1, Title: "a", Body: "b"}, {UserID: 1, ID: 2, Title: "c, d", Body: ""}}
+	cases := []struct {
+		name, file, content string
+		columns             map[string]string
+		want                []models.Post // nil means want
+	}{
+		{name: "json", file: "posts.json",
+			content: `[{"userId":1,"id":1,"title":"a","body":"b"},{"userId":1,"id":2,"title":"c, d"}]`},
+		{name: "ndjson", file: "posts.ndjson",
+			content: "{\"userId\":1,\"id\":1,\"title\":\"a\",\"body\":\"b\"}\n\n{\"userId\":1,\"id\":2,\"title\":\"c, d\"}\n"},
+		{name: "csv", file: "posts.csv",
+			content: "\ufeffUserID,id,title,body\n1,1,a,b\n 1 ,2,\"c, d\",\n"},
+		{name: "csv mapped", file: "posts.csv",
+			content: "author,post,headline\n1,1,a\n1,2,\"c, d\"\n",
+			columns: map[string]string{"userId": "author", "id": "post", "title": "headline"},
+			want:    []models.Post{{UserID: 1, ID: 1, Title: "a"}, {UserID: 1, ID: 2, Title: "c, d"}}},
+		{name: "gzip", file: "posts.ndjson.gz",
+			content: gzipped(t, "{\"userId\":1,\"id\":1,\"title\":\"a\",\"body\":\"b\"}\n{\"userId\":1,\"id\":2,\"title\":\"c, d\"}\n")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			writeFile(t, path, tc.content)
+			c := NewFileCollector(path)
+			c.Columns = tc.columns
+			want := want
+			if tc.want != nil {
+				want = tc.want
+			}
+			got, err := c.Fetch(context.Background())
+			if err != nil || !reflect.DeepEqual(stripRaw(got), want) {
+				t.Fatalf("got %+v, %v; want %+v", got, err, want)
+			}
+		})
+	}
+}
+
+func TestFileCollector_KeepsRaw(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "posts.json"), `[ {"userId": 1, "id": 1, "tags": ["a"]} ]`)
+	writeFile(t, filepath.Join(dir, "posts.csv"), "userId, id ,lang\n1,1,en\n")
+	for file, want := range map[string]string{
+		"posts.json": `{"id":1,"tags":["a"],"userId":1}`,
+		"posts.csv":  `{"id":"1","lang":"en","userId":"1"}`,
+	} {
+		got, err := NewFileCollector(filepath.Join(dir, file)).Fetch(context.Background())
+		if err != nil || len(got) != 1 || string(got[0].Raw) != want {
+			t.Errorf("%s: got %+v, %v; want raw %s", file, got, err, want)
+		}
+	}
+}
+
+func TestFileCollector_Errors(t *testing.T) {
+	cases := []struct {
+		name, file, content string
+		format              FileFormat
+		columns             map[string]string
+		decode              bool // expect ErrDecode
+	}{
+		{name: "unknown extension", file: "posts.txt", content: "[]", decode: true},
+		{name: "unknown format", file: "posts.json", content: "[]", format: "xml"},
+		{name: "unknown column field", file: "posts.csv", content: "id\n", columns: map[string]string{"author": "a"}},
+		{name: "missing column", file: "posts.csv", content: "userId,title\n1,a\n", decode: true},
+		{name: "bad int", file: "posts.csv", content: "userId,id\n1,1\n1,x\n", decode: true},
+		{name: "ragged row", file: "posts.csv", content: "userId,id\n1,1,extra\n", decode: true},
+		{name: "bad ndjson", file: "posts.ndjson", content: "{\"userId\":1,\"id\":1}\n{\"userId\":\n", decode: true},
+		{name: "not an array", file: "posts.json", content: `{"userId":1}`, decode: true},
+		{name: "bad gzip", file: "posts.json.gz", content: "\x1f\x8b garbage", decode: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			writeFile(t, path, tc.content)
+			c := NewFileCollector(path)
+			c.Format = tc.format
+			c.Columns = tc.columns
+			_, err := c.Fetch(context.Background())
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if errors.Is(err, ErrDecode) != tc.decode {
+				t.Fatalf("ErrDecode = %v, want %v: %v", errors.Is(err, ErrDecode), tc.decode, err)
+			}
+		})
+	}
+}
+
+func TestFileCollector_Batches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posts.csv")
+	writeFile(t, path, "userId,id\n1,1\n1,2\n1,3\n")
+	c := NewFileCollector(path)
+	c.BatchSize = 2
+	var sizes []int
+	err := c.Stream(context.Background(), func(batch []models.Post) error {
+		sizes = append(sizes, len(batch))
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(sizes, []int{2, 1}) {
+		t.Fatalf("batches %v, err %v", sizes, err)
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestDirCollector_Stream(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "1.json"), `[{"userId":1,"id":1}]`)
+	writeFile(t, filepath.Join(dir, "2.csv"), "userId,id\n1,x\n")
+	writeFile(t, filepath.Join(dir, "3.ndjson"), `{"userId":1,"id":3}`)
+	writeFile(t, filepath.Join(dir, "4.json.part"), `[`)
+	writeFile(t, filepath.Join(dir, ".5.json"), `[`)
+
+	c := NewDirCollector(dir)
+	var got []models.Post
+	err := c.Stream(context.Background(), func(batch []models.Post) error {
+		got = append(got, batch...)
+		return nil
+	})
+	if !errors.Is(err, ErrDecode) {
+		t.Fatalf("expected the CSV to fail decoding, got %v", err)
+	}
+	if want := []models.Post{{UserID: 1, ID: 1}, {UserID: 1, ID: 3}}; !reflect.DeepEqual(stripRaw(got), want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if names := listDir(t, dir); !reflect.DeepEqual(names, []string{".5.json", "4.json.part"}) {
+		t.Fatalf("left in dir: %v", names)
+	}
+	if names := listDir(t, filepath.Join(dir, "done")); !reflect.DeepEqual(names, []string{"1.json", "3.ndjson"}) {
+		t.Fatalf("done: %v", names)
+	}
+	if names := listDir(t, filepath.Join(dir, "failed")); !reflect.DeepEqual(names, []string{"2.csv"}) {
+		t.Fatalf("failed: %v", names)
+	}
+
+	// nothing left to do
+	err = c.Stream(context.Background(), func([]models.Post) error { return nil })
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("expected ErrNotModified, got %v", err)
+	}
+
+	// a file of the same name dropped again is kept alongside the first
+	writeFile(t, filepath.Join(dir, "1.json"), `[]`)
+	if err := c.Stream(context.Background(), func([]models.Post) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if names := listDir(t, filepath.Join(dir, "done")); !reflect.DeepEqual(names, []string{"1.json", "1.json.1", "3.ndjson"}) {
+		t.Fatalf("done: %v", names)
+	}
+}
+
+func TestDirCollector_WriteErrorLeavesFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "1.json"), `[{"userId":1,"id":1}]`)
+	writeFile(t, filepath.Join(dir, "2.json"), `[{"userId":1,"id":2}]`)
+
+	c := NewDirCollector(dir)
+	c.Pattern = "*.json"
+	c.DoneDir = filepath.Join(t.TempDir(), "archive")
+	boom := errors.New("db down")
+	calls := 0
+	err := c.Stream(context.Background(), func([]models.Post) error {
+		if calls++; calls == 2 {
+			return boom
+		}
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	if names := listDir(t, dir); !reflect.DeepEqual(names, []string{"2.json"}) {
+		t.Fatalf("left in dir: %v", names)
+	}
+	if names := listDir(t, c.DoneDir); !reflect.DeepEqual(names, []string{"1.json"}) {
+		t.Fatalf("done: %v", names)
+	}
+	if names := listDir(t, filepath.Join(dir, "failed")); len(names) != 0 {
+		t.Fatalf("failed: %v", names)
+	}
+}
+
+func TestDirCollector_MinAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "1.json")
+	writeFile(t, path, `[{"userId":1,"id":1}]`)
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewDirCollector(dir)
+	c.MinAge = time.Minute
+	c.now = func() time.Time { return mtime.Add(30 * time.Second) }
+	if err := c.Stream(context.Background(), func([]models.Post) error { return nil }); !errors.Is(err, ErrNotModified) {
+		t.Fatalf("expected a fresh file to be skipped, got %v", err)
+	}
+	c.now = func() time.Time { return mtime.Add(time.Minute) }
+	if err := c.Stream(context.Background(), func([]models.Post) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if names := listDir(t, filepath.Join(dir, "done")); !reflect.DeepEqual(names, []string{"1.json"}) {
+		t.Fatalf("done: %v", names)
+	}
+}
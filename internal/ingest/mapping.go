@@ -0,0 +1,271 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// FieldSpec locates one post field in an upstream item.
+type FieldSpec struct {
+	// Path is a dotted path such as "author.id" or a JSON pointer such as
+	// "/author/id"; numeric segments index arrays. Empty means the field's
+	// own name.
+	Path string
+
+	// Default is used when the value is missing or null; nil means none.
+	// Title and body are then left empty; an item without a positive userId
+	// or id fails the fetch with ErrDecode.
+	Default any
+}
+
+// FieldMapping decodes upstream items of arbitrary shape into posts. Values
+// are coerced to the field's type: numeric strings to integers, and numbers
+// and booleans to strings. A value that cannot be coerced fails the fetch
+// with ErrDecode, as does an item that yields no positive userId or id.
+type FieldMapping struct {
+	root     []string
+	rootPath string
+	fields   []mappedField // one per postFields entry
+}
+
+type mappedField struct {
+	path []string
+	def  any
+}
+
+// NewFieldMapping returns the mapping reading the items array at root, a
+// path like those of FieldSpec through objects only (empty for a top-level
+// array), and each post field (userId, id, title, body) as specified in
+// fields. Fields without a spec are read from their own name.
+func NewFieldMapping(root string, fields map[string]FieldSpec) (*FieldMapping, error) {
+	for f := range fields {
+		if !slices.Contains(postFields, f) {
+			return nil, fmt.Errorf("mapping: unknown post field %q", f)
+		}
+	}
+	m := &FieldMapping{rootPath: root}
+	if root != "" {
+		var err error
+		if m.root, err = parsePath(root); err != nil {
+			return nil, fmt.Errorf("mapping: root: %w", err)
+		}
+	}
+	for _, f := range postFields {
+		spec := fields[f]
+		path := []string{f}
+		if spec.Path != "" {
+			var err error
+			if path, err = parsePath(spec.Path); err != nil {
+				return nil, fmt.Errorf("mapping: %s: %w", f, err)
+			}
+		}
+		if spec.Default != nil {
+			if _, err := coerce(f, spec.Default); err != nil {
+				return nil, fmt.Errorf("mapping: %s: default: %w", f, err)
+			}
+		}
+		m.fields = append(m.fields, mappedField{path: path, def: spec.Default})
+	}
+	return m, nil
+}
+
+// parsePath splits a dotted path or a JSON pointer (RFC 6901) into keys.
+func parsePath(p string) ([]string, error) {
+	if strings.HasPrefix(p, "/") {
+		keys := strings.Split(p[1:], "/")
+		for i, k := range keys {
+			keys[i] = pointerUnescaper.Replace(k)
+		}
+		return keys, nil
+	}
+	keys := strings.Split(p, ".")
+	if slices.Contains(keys, "") {
+		return nil, fmt.Errorf("invalid path %q", p)
+	}
+	return keys, nil
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// decode decodes the items array at the mapping's root of r, calling fn
+// with batches of at most batchSize posts. A missing root fails with
+// ErrDecode rather than yielding no posts.
+func (m *FieldMapping) decode(r io.Reader, batchSize int, fn func([]models.Post) error) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := m.seekRoot(dec); err != nil {
+		return err
+	}
+	return decodeElements(dec, batchSize, m.decodePost, fn)
+}
+
+// decodeItems maps the items of a JSON array, ignoring the root.
+func (m *FieldMapping) decodeItems(raw []byte) ([]models.Post, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var posts []models.Post
+	err := decodeElements(dec, 0, m.decodePost, func(batch []models.Post) error {
+		posts = append(posts, batch...)
+		return nil
+	})
+	return posts, err
+}
+
+// seekRoot advances dec to the value at the root, skipping the other members
+// of the enclosing objects.
+func (m *FieldMapping) seekRoot(dec *json.Decoder) error {
+	for _, key := range m.root {
+		tok, err := dec.Token()
+		if err != nil {
+			return decodeError(err)
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '{' {
+			return fmt.Errorf("%w: root %q: expected JSON object, got %v", ErrDecode, m.rootPath, tok)
+		}
+		for {
+			if !dec.More() {
+				return fmt.Errorf("%w: root %q not found", ErrDecode, m.rootPath)
+			}
+			tok, err := dec.Token()
+			if err != nil {
+				return decodeError(err)
+			}
+			if tok == key {
+				break
+			}
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return decodeError(err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *FieldMapping) decodePost(dec *json.Decoder) (models.Post, error) {
+	var item any
+	if err := dec.Decode(&item); err != nil { // dec uses UseNumber
+		return models.Post{}, err
+	}
+	raw, err := marshalCanonical(item)
+	if err != nil {
+		return models.Post{}, err
+	}
+	p := models.Post{Raw: raw}
+	for i, f := range m.fields {
+		v := lookup(item, f.path)
+		if v == nil {
+			if v = f.def; v == nil {
+				continue
+			}
+		}
+		name := postFields[i]
+		c, err := coerce(name, v)
+		if err != nil {
+			return models.Post{}, fmt.Errorf("%w: %s: %w", ErrDecode, name, err)
+		}
+		switch name {
+		case "userId":
+			p.UserID = c.(int)
+		case "id":
+			p.ID = c.(int)
+		case "title":
+			p.Title = c.(string)
+		case "body":
+			p.Body = c.(string)
+		}
+	}
+	// a zero key would make every such item overwrite the same post
+	if p.UserID <= 0 || p.ID <= 0 {
+		return models.Post{}, fmt.Errorf("%w: item without a positive userId and id", ErrDecode)
+	}
+	return p, nil
+}
+
+// lookup returns the value at path in v, or nil if there is none.
+func lookup(v any, path []string) any {
+	for _, key := range path {
+		switch t := v.(type) {
+		case map[string]any:
+			v = t[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil
+			}
+			v = t[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// coerce converts a decoded JSON value to the type of the named post field.
+func coerce(field string, v any) (any, error) {
+	if field == "userId" || field == "id" {
+		return toInt(v)
+	}
+	return toString(v)
+}
+
+func toInt(v any) (int, error) {
+	var s string
+	switch t := v.(type) {
+	case int:
+		return t, nil
+	case json.Number:
+		s = t.String()
+	case float64: // defaults decoded without UseNumber
+		s = strconv.FormatFloat(t, 'f', -1, 64)
+	case string:
+		s = strings.TrimSpace(t)
+	default:
+		return 0, fmt.Errorf("cannot convert %s to an integer", jsonKind(v))
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, nil
+	}
+	// integral numbers written as e.g. 12.0 or 1e3
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return 0, fmt.Errorf("%q is not an integer", s)
+	}
+	return int(f), nil
+}
+
+func toString(v any) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	default:
+		return "", fmt.Errorf("cannot convert %s to a string", jsonKind(v))
+	}
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case bool:
+		return "a boolean"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
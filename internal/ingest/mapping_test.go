@@ -0,0 +1,163 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+func decodeMapped(t *testing.T, m *FieldMapping, body string) ([]models.Post, error) {
+	t.Helper()
+	var posts []models.Post
+	err := m.decode(strings.NewReader(body), 2, func(batch []models.Post) error {
+		posts = append(posts, batch...)
+		return nil
+	})
+	return posts, err
+}
+
+func TestFieldMapping_Decode(t *testing.T) {
+	m, err := NewFieldMapping("data.items", map[string]FieldSpec{
+		"userId": {Path: "author.id"},
+		"id":     {Path: "/meta/post~1id"},
+		"title":  {Path: "headline", Default: "untitled"},
+		"body":   {Path: "paragraphs.0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"status":"ok","paging":{"next":null},"data":{"count":3,"items":[
+		{"author":{"id":"7"},"meta":{"post/id":1},"headline":"a","paragraphs":["first","second"]},
+		{"author":{"id":7},"meta":{"post/id":"2"},"headline":null,"paragraphs":[]},
+		{"author":{"id":7.0},"meta":{"post/id":3e0},"headline":42,"paragraphs":[true]}
+	]}}`
+	got, err := decodeMapped(t, m, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Post{
+		{UserID: 7, ID: 1, Title: "a", Body: "first"},
+		{UserID: 7, ID: 2, Title: "untitled"},
+		{UserID: 7, ID: 3, Title: "42", Body: "true"},
+	}
+	if !reflect.DeepEqual(stripRaw(got), want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if raw := `{"author":{"id":7},"headline":null,"meta":{"post/id":"2"},"paragraphs":[]}`; string(got[1].Raw) != raw {
+		t.Fatalf("raw = %s, want %s", got[1].Raw, raw)
+	}
+}
+
+func TestFieldMapping_DefaultsToFieldNames(t *testing.T) {
+	m, err := NewFieldMapping("", map[string]FieldSpec{"userId": {Default: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeMapped(t, m, `[{"id":"5","title":"t","body":"b","extra":{}}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []models.Post{{UserID: 1, ID: 5, Title: "t", Body: "b"}}; !reflect.DeepEqual(stripRaw(got), want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFieldMapping_DecodeErrors(t *testing.T) {
+	cases := []struct{ name, root, body string }{
+		{"root missing", "data.items", `{"data":{"other":[]}}`},
+		{"root not an object", "data.items", `{"data":[]}`},
+		{"not an array", "", `{"id":1}`},
+		{"id not numeric", "", `[{"userId":1,"id":"abc"}]`},
+		{"id fractional", "", `[{"userId":1,"id":1.5}]`},
+		{"title is an object", "", `[{"userId":1,"id":1,"title":{}}]`},
+		{"id missing", "", `[{"userId":1,"id":1},{"userId":1,"title":"x"}]`},
+		{"userId null", "", `[{"userId":null,"id":1}]`},
+		{"id zero", "", `[{"userId":1,"id":"0"}]`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewFieldMapping(tc.root, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := decodeMapped(t, m, tc.body); !errors.Is(err, ErrDecode) {
+				t.Fatalf("expected ErrDecode, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewFieldMapping_Invalid(t *testing.T) {
+	cases := []struct {
+		name   string
+		root   string
+		fields map[string]FieldSpec
+	}{
+		{"unknown field", "", map[string]FieldSpec{"author": {Path: "a"}}},
+		{"empty segment", "", map[string]FieldSpec{"id": {Path: "a..b"}}},
+		{"bad root", "data.", nil},
+		{"bad default", "", map[string]FieldSpec{"userId": {Default: "me"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewFieldMapping(tc.root, tc.fields); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestHTTPCollector_Mapping(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"results":[{"uid":"1","pid":"10","name":"a"}]}`))
+	}))
+	defer s.Close()
+
+	m, err := NewFieldMapping("results", map[string]FieldSpec{
+		"userId": {Path: "uid"}, "id": {Path: "pid"}, "title": {Path: "name"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewHTTPCollector(s.URL, 2*time.Second)
+	c.Mapping = m
+	got, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []models.Post{{UserID: 1, ID: 10, Title: "a"}}; !reflect.DeepEqual(stripRaw(got), want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestPaginatedCollector_Mapping(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = w.Write([]byte(`{"data":[{"u":1,"p":"1"}],"next_cursor":"c2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"u":1,"p":"2"}],"next_cursor":null}`))
+	}))
+	defer s.Close()
+
+	m, err := NewFieldMapping("", map[string]FieldSpec{"userId": {Path: "u"}, "id": {Path: "p"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewPaginatedCollector(s.URL, 2*time.Second, Pagination{Mode: PaginateCursor})
+	c.Mapping = m
+	got, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []models.Post{{UserID: 1, ID: 1}, {UserID: 1, ID: 2}}; !reflect.DeepEqual(stripRaw(got), want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
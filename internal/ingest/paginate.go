@@ -0,0 +1,344 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// PaginationMode selects how the next page of an upstream listing is located.
+type PaginationMode string
+
+const (
+	PaginatePage   PaginationMode = "page"   // ?page=N&limit=M
+	PaginateOffset PaginationMode = "offset" // ?_start=N&_limit=M
+	PaginateCursor PaginationMode = "cursor" // opaque cursor returned in the body
+	PaginateLink   PaginationMode = "link"   // RFC 5988 Link: <...>; rel="next"
+)
+
+// DefaultOffsetPageSize is the page size in offset mode when none is set:
+// offsets advance by the page size, so it cannot be left to the upstream.
+const DefaultOffsetPageSize = 100
+
+// ErrTooManyPages is returned when a listing does not end within MaxPages.
+var ErrTooManyPages = errors.New("pagination: max pages exceeded")
+
+// Pagination describes an upstream's paging contract. Zero values are
+// replaced by per-mode defaults, see withDefaults.
+type Pagination struct {
+	Mode     PaginationMode
+	PageSize int // items requested per page; 0 leaves it to the upstream, except in offset mode
+	MaxPages int // safety cap on the number of requests per Fetch
+
+	PageParam string // page number (page mode), offset (offset mode) or cursor (cursor mode)
+	SizeParam string // page size parameter
+	FirstPage int    // first page number in page mode, usually 1
+
+	ItemsPath  string // dotted path to the items array when pages are objects, e.g. "data"
+	CursorPath string // dotted path to the next cursor in cursor mode, e.g. "meta.next_cursor"
+}
+
+func (p Pagination) withDefaults() Pagination {
+	if p.MaxPages <= 0 {
+		p.MaxPages = 1000
+	}
+	switch p.Mode {
+	case PaginatePage:
+		p.PageParam = orDefault(p.PageParam, "page")
+		p.SizeParam = orDefault(p.SizeParam, "limit")
+		if p.FirstPage == 0 {
+			p.FirstPage = 1
+		}
+	case PaginateOffset:
+		p.PageParam = orDefault(p.PageParam, "_start")
+		p.SizeParam = orDefault(p.SizeParam, "_limit")
+		if p.PageSize <= 0 {
+			p.PageSize = DefaultOffsetPageSize
+		}
+	case PaginateCursor:
+		p.PageParam = orDefault(p.PageParam, "cursor")
+		p.SizeParam = orDefault(p.SizeParam, "limit")
+		p.CursorPath = orDefault(p.CursorPath, "next_cursor")
+		p.ItemsPath = orDefault(p.ItemsPath, "data")
+	case PaginateLink:
+		p.SizeParam = orDefault(p.SizeParam, "per_page")
+	}
+	return p
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// PaginatedCollector walks a paged upstream listing and returns the
+// concatenation of all pages.
+type PaginatedCollector struct {
+	Client       *http.Client
+	SourceURL    string
+	Pagination   Pagination
+	MaxBodyBytes int64         // per page; 0 means unlimited
+	Auth         Authenticator // optional credentials added to every request
+
+	// Mapping, when set, maps the items of each page. Its root is not used;
+	// Pagination.ItemsPath locates the items.
+	Mapping *FieldMapping
+}
+
+// Ensure PaginatedCollector implements the StreamCollector interface.
+var _ StreamCollector = (*PaginatedCollector)(nil)
+
+func NewPaginatedCollector(sourceURL string, timeout time.Duration, p Pagination) *PaginatedCollector {
+	return &PaginatedCollector{
+		SourceURL:  sourceURL,
+		Client:     newHTTPClient(timeout),
+		Pagination: p,
+	}
+}
+
+func (c *PaginatedCollector) Fetch(ctx context.Context) ([]models.Post, error) {
+	var all []models.Post
+	err := c.Stream(ctx, func(page []models.Post) error {
+		all = append(all, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// Stream requests pages in order, handing each one to fn, until the listing
+// is exhausted.
+func (c *PaginatedCollector) Stream(ctx context.Context, fn func([]models.Post) error) error {
+	p := c.Pagination.withDefaults()
+	switch p.Mode {
+	case PaginatePage, PaginateOffset, PaginateCursor, PaginateLink:
+	default:
+		return fmt.Errorf("pagination: unknown mode %q", p.Mode)
+	}
+
+	base, err := url.Parse(c.SourceURL)
+	if err != nil {
+		return err
+	}
+
+	next := c.firstURL(base, p)
+	seen := map[string]bool{}
+	for page := 0; page < p.MaxPages; page++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if seen[next.String()] {
+			return fmt.Errorf("pagination: upstream repeated page %s", next)
+		}
+		seen[next.String()] = true
+
+		items, cursor, link, err := c.fetchPage(ctx, next, p)
+		if err != nil {
+			return fmt.Errorf("pagination: page %d: %w", page+1, err)
+		}
+		if len(items) > 0 {
+			if err := fn(items); err != nil {
+				return err
+			}
+		}
+
+		switch p.Mode {
+		case PaginatePage, PaginateOffset:
+			if len(items) == 0 || (p.PageSize > 0 && len(items) < p.PageSize) {
+				return nil
+			}
+			next = c.pageURL(base, p, page+1)
+		case PaginateCursor:
+			if cursor == "" || len(items) == 0 {
+				return nil
+			}
+			next = withQuery(base, p.SizeParam, p.PageSize, p.PageParam, cursor)
+		case PaginateLink:
+			if link == "" {
+				return nil
+			}
+			ref, err := url.Parse(link)
+			if err != nil {
+				return fmt.Errorf("pagination: invalid next link %q: %w", link, err)
+			}
+			next = next.ResolveReference(ref)
+		}
+	}
+	return ErrTooManyPages
+}
+
+func (c *PaginatedCollector) firstURL(base *url.URL, p Pagination) *url.URL {
+	switch p.Mode {
+	case PaginatePage, PaginateOffset:
+		return c.pageURL(base, p, 0)
+	default:
+		return withQuery(base, p.SizeParam, p.PageSize, "", "")
+	}
+}
+
+// pageURL returns the URL of the n-th (zero-based) page in page/offset mode.
+func (c *PaginatedCollector) pageURL(base *url.URL, p Pagination, n int) *url.URL {
+	v := p.FirstPage + n
+	if p.Mode == PaginateOffset {
+		v = n * p.PageSize
+	}
+	return withQuery(base, p.SizeParam, p.PageSize, p.PageParam, strconv.Itoa(v))
+}
+
+func withQuery(base *url.URL, sizeParam string, size int, param, value string) *url.URL {
+	u := *base
+	q := u.Query()
+	if size > 0 {
+		q.Set(sizeParam, strconv.Itoa(size))
+	}
+	if param != "" {
+		q.Set(param, value)
+	}
+	u.RawQuery = q.Encode()
+	return &u
+}
+
+func (c *PaginatedCollector) fetchPage(ctx context.Context, u *url.URL, p Pagination) (items []models.Post, cursor, next string, err error) {
+	resp, err := get(ctx, c.Client, c.Auth, u.String())
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(limitBody(resp.Body, c.MaxBodyBytes))
+	if err != nil {
+		return nil, "", "", transportError(err)
+	}
+
+	raw := json.RawMessage(body)
+	if p.ItemsPath != "" {
+		if raw, err = lookupPath(body, p.ItemsPath); err != nil {
+			return nil, "", "", fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+	}
+	if raw != nil && c.Mapping != nil {
+		if items, err = c.Mapping.decodeItems(raw); err != nil {
+			return nil, "", "", err
+		}
+	} else if raw != nil {
+		if err := decodeArray(bytes.NewReader(raw), 0, func(batch []models.Post) error {
+			items = append(items, batch...)
+			return nil
+		}); err != nil {
+			return nil, "", "", err
+		}
+	}
+	if p.Mode == PaginateCursor {
+		if cursor, err = cursorAt(body, p.CursorPath); err != nil {
+			return nil, "", "", fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+	}
+	return items, cursor, nextLink(resp.Header), nil
+}
+
+// lookupPath returns the JSON value at a dotted path such as "meta.next".
+// A missing key yields a nil value and no error.
+func lookupPath(doc []byte, path string) (json.RawMessage, error) {
+	cur := json.RawMessage(doc)
+	for _, key := range strings.Split(path, ".") {
+		if cur == nil || bytes.Equal(bytes.TrimSpace(cur), []byte("null")) {
+			return nil, nil
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(cur, &obj); err != nil {
+			return nil, fmt.Errorf("path %q: %w", path, err)
+		}
+		cur = obj[key]
+	}
+	return cur, nil
+}
+
+// cursorAt extracts a string or numeric cursor at path; null or missing means
+// there is no next page.
+func cursorAt(doc []byte, path string) (string, error) {
+	raw, err := lookupPath(doc, path)
+	if err != nil || raw == nil {
+		return "", err
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	switch c := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return c, nil
+	case json.Number:
+		return c.String(), nil
+	default:
+		return "", fmt.Errorf("cursor at %q is not a string or number", path)
+	}
+}
+
+// nextLink returns the target of the rel="next" entry of RFC 5988 Link
+// headers, or "" if there is none.
+func nextLink(h http.Header) string {
+	for _, header := range h.Values("Link") {
+		for _, part := range splitLink(header, ',') {
+			segs := splitLink(part, ';')
+			target := strings.TrimSpace(segs[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range segs[1:] {
+				k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(k), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(v, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitLink splits a Link header value on sep, ignoring separators inside a
+// <target> or a quoted parameter value.
+func splitLink(s string, sep byte) []string {
+	var parts []string
+	start, inTarget, inQuote := 0, false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inQuote && c == '\\':
+			i++ // skip the escaped character
+		case inQuote:
+			inQuote = c != '"'
+		case inTarget:
+			inTarget = c != '>'
+		case c == '<':
+			inTarget = true
+		case c == '"':
+			inQuote = true
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,210 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// pageOf renders posts with ids [from, to) as a JSON array.
+func pageOf(from, to int) string {
+	s := "["
+	for i := from; i < to; i++ {
+		if i > from {
+			s += ","
+		}
+		s += fmt.Sprintf(`{"userId":1,"id":%d,"title":"t","body":"b"}`, i)
+	}
+	return s + "]"
+}
+
+func TestPaginated_PageMode(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if r.URL.Query().Get("limit") != "2" {
+			t.Errorf("missing page size, query=%s", r.URL.RawQuery)
+		}
+		switch page {
+		case 1:
+			_, _ = w.Write([]byte(pageOf(1, 3)))
+		case 2:
+			_, _ = w.Write([]byte(pageOf(3, 4))) // short page ends the listing
+		default:
+			t.Errorf("unexpected page %d", page)
+		}
+	}))
+	defer s.Close()
+
+	c := NewPaginatedCollector(s.URL, 2*time.Second, Pagination{Mode: PaginatePage, PageSize: 2})
+	posts, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Fatalf("expected 3 posts, got %d", len(posts))
+	}
+}
+
+func TestPaginated_OffsetMode(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("_start"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("_limit"))
+		end := min(start+limit, 5)
+		if start >= end {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(pageOf(start, end)))
+	}))
+	defer s.Close()
+
+	c := NewPaginatedCollector(s.URL, 2*time.Second, Pagination{Mode: PaginateOffset, PageSize: 2})
+	posts, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 5 || posts[4].ID != 4 {
+		t.Fatalf("expected ids 0..4, got %+v", posts)
+	}
+}
+
+func TestPaginated_OffsetModeDefaultPageSize(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("_start"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("_limit"))
+		if limit != DefaultOffsetPageSize {
+			t.Errorf("_limit = %d, want %d", limit, DefaultOffsetPageSize)
+		}
+		end := min(start+limit, 150)
+		if start >= end {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(pageOf(start, end)))
+	}))
+	defer s.Close()
+
+	c := NewPaginatedCollector(s.URL, 2*time.Second, Pagination{Mode: PaginateOffset})
+	posts, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 150 || posts[149].ID != 149 {
+		t.Fatalf("expected ids 0..149, got %d posts", len(posts))
+	}
+}
+
+func TestPaginated_CursorMode(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			_, _ = fmt.Fprintf(w, `{"data":%s,"meta":{"next":"abc"}}`, pageOf(1, 3))
+		case "abc":
+			_, _ = fmt.Fprintf(w, `{"data":%s,"meta":{"next":null}}`, pageOf(3, 5))
+		default:
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("cursor"))
+		}
+	}))
+	defer s.Close()
+
+	c := NewPaginatedCollector(s.URL, 2*time.Second, Pagination{Mode: PaginateCursor, CursorPath: "meta.next"})
+	posts, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 4 {
+		t.Fatalf("expected 4 posts, got %d", len(posts))
+	}
+}
+
+func TestPaginated_LinkMode(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/posts":
+			w.Header().Set("Link", `</posts/2>; rel="next", </posts/9>; rel="last"`)
+			_, _ = w.Write([]byte(pageOf(1, 3)))
+		case "/posts/2":
+			w.Header().Set("Link", `</posts>; rel="first"`)
+			_, _ = w.Write([]byte(pageOf(3, 4)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	c := NewPaginatedCollector(s.URL+"/posts", 2*time.Second, Pagination{Mode: PaginateLink})
+	posts, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Fatalf("expected 3 posts, got %d", len(posts))
+	}
+}
+
+func TestPaginated_MaxPagesCap(t *testing.T) {
+	var calls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(pageOf(calls, calls+1)))
+	}))
+	defer s.Close()
+
+	c := NewPaginatedCollector(s.URL, 2*time.Second, Pagination{Mode: PaginatePage, PageSize: 1, MaxPages: 3})
+	if _, err := c.Fetch(context.Background()); !errors.Is(err, ErrTooManyPages) {
+		t.Fatalf("expected ErrTooManyPages, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 requests, got %d", calls)
+	}
+}
+
+func TestPaginated_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel() // cancel after serving the first page
+		_, _ = w.Write([]byte(pageOf(1, 2)))
+	}))
+	defer s.Close()
+
+	c := NewPaginatedCollector(s.URL, 2*time.Second, Pagination{Mode: PaginatePage, PageSize: 1})
+	if _, err := c.Fetch(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPaginated_UnknownMode(t *testing.T) {
+	c := NewPaginatedCollector("http://example.invalid", time.Second, Pagination{Mode: "bogus"})
+	if _, err := c.Fetch(context.Background()); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	h := http.Header{}
+	h.Add("Link", `<https://api.example.com/items?page=1>; rel="prev", <https://api.example.com/items?page=3>; rel="next"`)
+	if got := nextLink(h); got != "https://api.example.com/items?page=3" {
+		t.Fatalf("unexpected next link %q", got)
+	}
+	if got := nextLink(http.Header{}); got != "" {
+		t.Fatalf("expected no link, got %q", got)
+	}
+
+	// separators inside the target or a quoted value do not split entries
+	cases := map[string]string{
+		`<https://api.example.com/items?ids=1,2;v=3&page=2>; rel="next"`:            "https://api.example.com/items?ids=1,2;v=3&page=2",
+		`</a>; title="a, b; rel=next"; rel="prev", </b?x=1,2>; rel="next"`:          "/b?x=1,2",
+		`</a>; title="say \"hi\", then; go"; rel="last", </c>; rel="next"`:          "/c",
+		`<https://api.example.com/items?page=2>; rel="prev next", </z>; rel="next"`: "https://api.example.com/items?page=2",
+	}
+	for header, want := range cases {
+		if got := nextLink(http.Header{"Link": {header}}); got != want {
+			t.Errorf("nextLink(%s) = %q, want %q", header, got, want)
+		}
+	}
+}
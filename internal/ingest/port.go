@@ -2,6 +2,8 @@ package ingest
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/renix-codex/ingestor/internal/models"
 )
@@ -10,8 +12,122 @@ type CollectorPort interface {
 	Fetch(ctx context.Context) ([]models.Post, error)
 }
 
+// StreamCollector is implemented by collectors that can hand posts over in
+// batches as they are decoded instead of materialising the whole dataset.
+type StreamCollector interface {
+	CollectorPort
+	Stream(ctx context.Context, fn func([]models.Post) error) error
+}
+
+// RestartingStream is implemented by stream collectors that may start the
+// stream over, such as RetryCollector. restart is called before the first
+// batch of every attempt but the first, so consumers can drop what they
+// counted for batches that are about to be delivered again.
+type RestartingStream interface {
+	StreamRestarting(ctx context.Context, fn func([]models.Post) error, restart func()) error
+}
+
+// Committer is implemented by collectors holding state that may only be
+// persisted once the fetched posts have been stored, such as cache validators.
+type Committer interface {
+	Commit(ctx context.Context) error
+}
+
+// ValidatorStore persists HTTP cache validators per source so conditional
+// requests survive restarts.
+type ValidatorStore interface {
+	LoadValidators(ctx context.Context, source string) (Validators, error)
+	SaveValidators(ctx context.Context, source string, v Validators) error
+}
+
+// ErrNotFound is returned by StorePort lookups that match nothing.
+var ErrNotFound = errors.New("not found")
+
+// ErrDeleteThreshold is returned by StorePort.Tombstone when reconciliation
+// would delete more than the allowed fraction of a source's posts.
+var ErrDeleteThreshold = errors.New("reconcile: too many posts would be deleted")
+
+// ErrReconcileTooLarge fails a reconciled run whose snapshot has more than
+// MaxReconcilePosts posts; they are stored, but nothing is tombstoned.
+var ErrReconcileTooLarge = errors.New("reconcile: snapshot too large")
+
 type StorePort interface {
-	Upsert(ctx context.Context, items []models.EnrichedPost) error
-	QueryByUser(ctx context.Context, userID int) ([]models.EnrichedPost, error)
-	QueryRecent(ctx context.Context, limit, offset int) ([]models.EnrichedPost, error)
+	Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error)
+	GetPost(ctx context.Context, key models.PostKey) (models.EnrichedPost, error)
+	QueryPosts(ctx context.Context, q PostQuery) (PostPage, error)
+	SearchPosts(ctx context.Context, q SearchQuery) ([]models.SearchHit, error)
+	// ExportPosts streams every post matching q to fn without buffering;
+	// a positive q.Limit caps the number of posts.
+	ExportPosts(ctx context.Context, q PostQuery, fn func(models.EnrichedPost) error) error
+
+	// Tombstone marks the live posts of a source that are not in the
+	// request's Seen set as deleted and returns how many it marked.
+	Tombstone(ctx context.Context, req TombstoneRequest) (int, error)
+
+	// ingestion run history
+	CreateRun(ctx context.Context, run models.Run) (int64, error)
+	FinishRun(ctx context.Context, run models.Run) error
+	ListRuns(ctx context.Context, q RunQuery) ([]models.Run, error)
+	GetRun(ctx context.Context, id int64) (models.Run, error)
+	// FailStaleRuns marks the runs of source still recorded as running that
+	// started before startedBefore as failed with reason, and returns how
+	// many it marked.
+	FailStaleRuns(ctx context.Context, source string, startedBefore time.Time, reason string) (int, error)
+
+	// idempotency keys of pushed deliveries; LookupIdempotencyKey returns
+	// ErrNotFound for unknown or expired keys, ClaimIdempotencyKey stores rec
+	// unless a live key exists and returns the record that holds the key
+	LookupIdempotencyKey(ctx context.Context, source, key string) (IdempotencyRecord, error)
+	ClaimIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (IdempotencyRecord, error)
+
+	// post revision history
+	ListVersions(ctx context.Context, userID, id int) ([]models.PostVersion, error)
+	QueryAsOf(ctx context.Context, q AsOfQuery) ([]models.PostVersion, error)
+}
+
+// RunQuery selects ingestion runs, newest first.
+type RunQuery struct {
+	Source string // empty means all sources
+	Limit  int
+	Offset int
+}
+
+// AsOfQuery selects the revisions of posts that were current at At,
+// ordered by (user_id, id).
+type AsOfQuery struct {
+	At     time.Time
+	UserID int // 0 means all users
+	Limit  int
+	Offset int
+}
+
+// SearchQuery is a full-text search over the posts matching the filters of
+// PostQuery, best match first. Text takes words (all required), "quoted
+// phrases", prefix* terms, -excluded terms and OR between terms. Sort and
+// After do not apply; results are paged by Limit and Offset.
+type SearchQuery struct {
+	Text string
+	PostQuery
+}
+
+// PostPage is one page of posts; Next is nil on the last page.
+type PostPage struct {
+	Items []models.EnrichedPost
+	Next  *Cursor
+}
+
+// QueryOptions adjusts which posts a query returns and what they include.
+type QueryOptions struct {
+	IncludeDeleted bool // also return tombstoned posts
+	IncludeRaw     bool // return posts with their raw upstream object
+}
+
+// TombstoneRequest reconciles a source against a full snapshot.
+type TombstoneRequest struct {
+	Source string
+	Seen   []models.PostKey // every post in the snapshot
+	At     time.Time        // deletion time
+	// MaxFraction of the source's live posts that may be deleted at once;
+	// exceeding it fails with ErrDeleteThreshold and deletes nothing.
+	MaxFraction float64
 }
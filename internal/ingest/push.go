@@ -0,0 +1,183 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+var (
+	// ErrPushOnly is returned when a run is requested for a source that only
+	// receives pushed data.
+	ErrPushOnly = errors.New("source only accepts pushed data")
+	// ErrInvalidPush marks a pushed body that is not valid posts.
+	ErrInvalidPush = errors.New("invalid push")
+	// ErrIdempotencyMismatch is returned when an idempotency key is reused
+	// for a different delivery.
+	ErrIdempotencyMismatch = errors.New("idempotency key reused with a different request")
+)
+
+// PushRequest is a delivery of posts by a producer to a push source.
+type PushRequest struct {
+	Source string
+	Posts  []models.Post
+
+	// IdempotencyKey, if set, makes retries of the delivery return the run
+	// of the first one instead of ingesting again. RequestHash fingerprints
+	// the delivery so a key reused for other content is refused.
+	IdempotencyKey string
+	RequestHash    string
+}
+
+// IdempotencyRecord ties an idempotency key of a push source to the run
+// that processed it.
+type IdempotencyRecord struct {
+	Source      string
+	Key         string
+	RequestHash string
+	RunID       int64
+}
+
+// Push ingests a delivery to a push source as one run, exactly like a fetch
+// of a pulled source: the posts are enriched, upserted and, for reconciled
+// sources, treated as a full snapshot. It returns the finished run and
+// whether it is the replayed run of an earlier delivery with the same
+// idempotency key. Keys whose run failed may be retried.
+//
+// Deliveries to the same source are ingested one after another: Push waits
+// for the one in progress until ctx is done. The run is bounded by the
+// source's Timeout.
+func (s *Service) Push(ctx context.Context, req PushRequest) (models.Run, bool, error) {
+	src, err := s.pushSource(req.Source)
+	if err != nil {
+		return models.Run{}, false, err
+	}
+	select {
+	case src.pushing <- struct{}{}:
+		defer func() { <-src.pushing }()
+	case <-ctx.Done():
+		return models.Run{}, false, ctx.Err()
+	}
+	if src.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, src.opts.Timeout)
+		defer cancel()
+	}
+
+	if req.IdempotencyKey != "" {
+		rec, err := s.store.LookupIdempotencyKey(ctx, req.Source, req.IdempotencyKey)
+		if err == nil {
+			if run, ok, err := s.replay(ctx, src, req, rec); ok || err != nil {
+				return run, ok, err
+			}
+		} else if !errors.Is(err, ErrNotFound) {
+			return models.Run{}, false, err
+		}
+	}
+
+	src, run, err := s.start(ctx, req.Source, true)
+	if err != nil {
+		return models.Run{}, false, err
+	}
+	if req.IdempotencyKey != "" {
+		// a concurrent delivery may have claimed the key since the lookup
+		rec, err := s.store.ClaimIdempotencyKey(ctx, IdempotencyRecord{
+			Source: req.Source, Key: req.IdempotencyKey, RequestHash: req.RequestHash, RunID: run.ID,
+		})
+		if err != nil {
+			run.Status, run.Error = models.RunFailed, err.Error()
+			s.finish(src, run)
+			return run, false, err
+		}
+		if rec.RunID != run.ID {
+			// lost the race: drop this run and answer with the winner's
+			run.Status, run.Error = models.RunFailed, fmt.Sprintf("duplicate of run %d", rec.RunID)
+			s.finish(src, run)
+			winner, ok, err := s.replay(ctx, src, req, rec)
+			if !ok && err == nil {
+				err = fmt.Errorf("%w: %q", ErrRunInProgress, req.Source)
+			}
+			return winner, ok, err
+		}
+	}
+	run, _, err = s.run(ctx, src, sliceCollector(req.Posts), run)
+	return run, false, err
+}
+
+// pushSource returns the state of the named push source.
+func (s *Service) pushSource(name string) (*sourceState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, ok := s.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSource, name)
+	}
+	if src.pushing == nil {
+		return nil, fmt.Errorf("%w: %q does not accept pushed data", ErrUnknownSource, name)
+	}
+	return src, nil
+}
+
+// replay returns the run recorded for rec if the delivery may be answered
+// with it; ok is false if the earlier run failed and may be retried. A run
+// still recorded as running past the source's timeout was abandoned by a
+// stopped process; it is marked failed, so the delivery is retried.
+func (s *Service) replay(ctx context.Context, src *sourceState, req PushRequest, rec IdempotencyRecord) (models.Run, bool, error) {
+	if rec.RequestHash != req.RequestHash {
+		return models.Run{}, false, ErrIdempotencyMismatch
+	}
+	run, err := s.store.GetRun(ctx, rec.RunID)
+	if err != nil {
+		return models.Run{}, false, err
+	}
+	switch run.Status {
+	case models.RunFailed:
+		return models.Run{}, false, nil
+	case models.RunRunning:
+		if cutoff := src.opts.staleBefore(s.now().UTC()); !cutoff.IsZero() && run.StartedAt.Before(cutoff) {
+			if _, err := s.store.FailStaleRuns(ctx, req.Source, cutoff, abandonedRun); err != nil {
+				return models.Run{}, false, err
+			}
+			return models.Run{}, false, nil
+		}
+		return run, false, fmt.Errorf("%w: %q", ErrRunInProgress, req.Source)
+	}
+	return run, true, nil
+}
+
+// sliceCollector hands pushed posts to the ingestion pipeline.
+type sliceCollector []models.Post
+
+func (c sliceCollector) Fetch(ctx context.Context) ([]models.Post, error) { return c, nil }
+
+// DecodePosts decodes a pushed body: a JSON array of posts, or NDJSON with
+// one post object per line. Every post needs a positive userId and id.
+func DecodePosts(body []byte) ([]models.Post, error) {
+	var posts []models.Post
+	collect := func(batch []models.Post) error {
+		posts = append(posts, batch...)
+		return nil
+	}
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	var err error
+	switch {
+	case len(trimmed) == 0:
+		return nil, nil
+	case trimmed[0] == '[':
+		err = decodeArray(bytes.NewReader(trimmed), 0, collect)
+	default:
+		err = decodeNDJSON(bytes.NewReader(trimmed), 0, collect)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPush, err)
+	}
+	for i, p := range posts {
+		if p.UserID <= 0 || p.ID <= 0 {
+			return nil, fmt.Errorf("%w: post %d: userId and id must be positive", ErrInvalidPush, i)
+		}
+	}
+	return posts, nil
+}
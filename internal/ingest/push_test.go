@@ -0,0 +1,222 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+func TestDecodePosts(t *testing.T) {
+	want := []models.Post{{UserID: 1, ID: 2, Title: "a", Body: "b"}, {UserID: 1, ID: 3, Title: "c"}}
+	for name, body := range map[string]string{
+		"array":  `[{"userId":1,"id":2,"title":"a","body":"b"},{"userId":1,"id":3,"title":"c","extra":true}]`,
+		"ndjson": "{\"userId\":1,\"id\":2,\"title\":\"a\",\"body\":\"b\"}\n\n{\"userId\":1,\"id\":3,\"title\":\"c\"}\n",
+	} {
+		got, err := DecodePosts([]byte(body))
+		if err != nil || !reflect.DeepEqual(stripRaw(got), want) {
+			t.Errorf("%s: got %+v, %v", name, got, err)
+		}
+	}
+	got, _ := DecodePosts([]byte(`[{"userId":1, "id":3, "extra":true}]`))
+	if len(got) != 1 || string(got[0].Raw) != `{"extra":true,"id":3,"userId":1}` {
+		t.Errorf("raw: got %+v", got)
+	}
+	if got, err := DecodePosts([]byte(" \n")); err != nil || len(got) != 0 {
+		t.Errorf("empty body: got %+v, %v", got, err)
+	}
+	for name, body := range map[string]string{
+		"truncated array": `[{"userId":1,"id":2}`,
+		"bad line":        "{\"userId\":1,\"id\":2}\nnot json\n",
+		"wrong type":      `[{"userId":"one","id":2}]`,
+		"missing id":      `[{"userId":1,"title":"x"}]`,
+		"scalar":          `42`,
+	} {
+		if _, err := DecodePosts([]byte(body)); !errors.Is(err, ErrInvalidPush) {
+			t.Errorf("%s: want ErrInvalidPush, got %v", name, err)
+		}
+	}
+}
+
+// keyStore remembers idempotency keys on top of runStore.
+type keyStore struct {
+	runStore
+	keys map[string]IdempotencyRecord
+}
+
+func (k *keyStore) GetRun(ctx context.Context, id int64) (models.Run, error) {
+	if id < 1 || int(id) > len(k.runs) {
+		return models.Run{}, ErrNotFound
+	}
+	return k.runs[id-1], nil
+}
+
+func (k *keyStore) LookupIdempotencyKey(ctx context.Context, source, key string) (IdempotencyRecord, error) {
+	rec, ok := k.keys[source+"/"+key]
+	if !ok {
+		return IdempotencyRecord{}, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (k *keyStore) ClaimIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (IdempotencyRecord, error) {
+	if held, ok := k.keys[rec.Source+"/"+rec.Key]; ok && k.runs[held.RunID-1].Status != models.RunFailed {
+		return held, nil
+	}
+	k.keys[rec.Source+"/"+rec.Key] = rec
+	return rec, nil
+}
+
+func TestService_Push(t *testing.T) {
+	store := &keyStore{keys: map[string]IdempotencyRecord{}}
+	svc := New(store, fakeCollectorOK{}, "pulled", time.Now)
+	if err := svc.AddSource("pushed", nil, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	req := PushRequest{
+		Source:         "pushed",
+		Posts:          []models.Post{{UserID: 1, ID: 1}, {UserID: 1, ID: 2}, {UserID: 1, ID: 3}},
+		IdempotencyKey: "k1",
+		RequestHash:    "h1",
+	}
+
+	run, replayed, err := svc.Push(ctx, req)
+	if err != nil || replayed {
+		t.Fatalf("Push: %+v replayed=%v err=%v", run, replayed, err)
+	}
+	if run.Status != models.RunSucceeded || run.Fetched != 3 || run.Inserted != 2 || run.Updated != 1 {
+		t.Fatalf("unexpected run %+v", run)
+	}
+
+	// a retry is answered with the first run
+	again, replayed, err := svc.Push(ctx, req)
+	if err != nil || !replayed || again.ID != run.ID {
+		t.Fatalf("retry: %+v replayed=%v err=%v", again, replayed, err)
+	}
+	if len(store.runs) != 1 {
+		t.Fatalf("a replay must not start a run, got %d runs", len(store.runs))
+	}
+
+	// the same key for other content is refused
+	other := req
+	other.RequestHash = "h2"
+	if _, _, err := svc.Push(ctx, other); !errors.Is(err, ErrIdempotencyMismatch) {
+		t.Fatalf("want ErrIdempotencyMismatch, got %v", err)
+	}
+
+	// a failed run may be retried under its key
+	store.runs[0].Status = models.RunFailed
+	retried, replayed, err := svc.Push(ctx, req)
+	if err != nil || replayed || retried.ID == run.ID {
+		t.Fatalf("retry after failure: %+v replayed=%v err=%v", retried, replayed, err)
+	}
+}
+
+func TestService_PushStaleRun(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	store := &keyStore{keys: map[string]IdempotencyRecord{}}
+	svc := New(store, nil, "", func() time.Time { return now })
+	if err := svc.AddSource("pushed", nil, SourceOptions{Timeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	req := PushRequest{Source: "pushed", Posts: []models.Post{{UserID: 1, ID: 1}}, IdempotencyKey: "k", RequestHash: "h"}
+	// a delivery whose process stopped while ingesting it
+	store.runs = []models.Run{{ID: 1, Source: "pushed", Status: models.RunRunning, StartedAt: now.Add(-time.Minute)}}
+	store.keys["pushed/k"] = IdempotencyRecord{Source: "pushed", Key: "k", RequestHash: "h", RunID: 1}
+
+	if _, _, err := svc.Push(context.Background(), req); !errors.Is(err, ErrRunInProgress) {
+		t.Fatalf("within the timeout: want ErrRunInProgress, got %v", err)
+	}
+
+	store.runs[0].StartedAt = now.Add(-time.Minute - staleRunGrace - time.Second)
+	run, replayed, err := svc.Push(context.Background(), req)
+	if err != nil || replayed || run.ID != 2 || run.Status != models.RunSucceeded {
+		t.Fatalf("past the timeout: %+v replayed=%v err=%v", run, replayed, err)
+	}
+	if store.runs[0].Status != models.RunFailed || store.runs[0].Error != abandonedRun {
+		t.Fatalf("the abandoned run was not failed: %+v", store.runs[0])
+	}
+}
+
+// gateStore holds every Upsert until released.
+type gateStore struct {
+	runStore
+	entered, release chan struct{}
+}
+
+func (g *gateStore) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	g.entered <- struct{}{}
+	<-g.release
+	return g.runStore.Upsert(ctx, items)
+}
+
+func TestService_PushWaitsForPreviousDelivery(t *testing.T) {
+	store := &gateStore{entered: make(chan struct{}), release: make(chan struct{})}
+	svc := New(store, nil, "", time.Now)
+	if err := svc.AddSource("pushed", nil, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	req := PushRequest{Source: "pushed", Posts: []models.Post{{UserID: 1, ID: 1}}}
+	errs := make(chan error, 2)
+	push := func() {
+		_, _, err := svc.Push(context.Background(), req)
+		errs <- err
+	}
+
+	go push()
+	<-store.entered
+	go push()
+	select {
+	case <-store.entered:
+		t.Fatal("the second delivery was ingested while the first one was")
+	case err := <-errs:
+		t.Fatalf("the second delivery did not wait: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	store.release <- struct{}{}
+	<-store.entered
+	store.release <- struct{}{}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a delivery gives up waiting when its context is done
+	go push()
+	<-store.entered
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := svc.Push(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+	store.release <- struct{}{}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestService_PushAndPullSourcesDoNotMix(t *testing.T) {
+	store := &runStore{}
+	svc := New(store, fakeCollectorOK{}, "pulled", time.Now)
+	if err := svc.AddSource("pushed", nil, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, _, err := svc.Push(ctx, PushRequest{Source: "pulled"}); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("push to a pulled source: want ErrUnknownSource, got %v", err)
+	}
+	if _, err := svc.IngestSource(ctx, "pushed"); !errors.Is(err, ErrPushOnly) {
+		t.Errorf("ingesting a push source: want ErrPushOnly, got %v", err)
+	}
+	runs, err := svc.StartAll(ctx)
+	svc.Close()
+	if err != nil || len(runs) != 1 || runs[0].Source != "pulled" {
+		t.Errorf("StartAll must skip push sources, got %+v, %v", runs, err)
+	}
+}
@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned for a PostQuery that cannot be run as given.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// SortField is a column posts can be listed by.
+type SortField string
+
+const (
+	SortIngestedAt SortField = "ingested_at"
+	SortID         SortField = "id"
+	SortUserID     SortField = "user_id"
+	SortTitle      SortField = "title"
+	SortSource     SortField = "source"
+)
+
+// Sort orders a listing by Field, then by (id, user_id) in the same direction.
+type Sort struct {
+	Field SortField
+	Desc  bool
+}
+
+// DefaultSort lists the most recently ingested posts first.
+var DefaultSort = Sort{Field: SortIngestedAt, Desc: true}
+
+// ParseSort parses "field" (ascending) or "-field" (descending); an empty
+// string means DefaultSort.
+func ParseSort(s string) (Sort, error) {
+	if s == "" {
+		return DefaultSort, nil
+	}
+	sort := Sort{Field: SortField(strings.TrimPrefix(s, "-")), Desc: strings.HasPrefix(s, "-")}
+	switch sort.Field {
+	case SortIngestedAt, SortID, SortUserID, SortTitle, SortSource:
+		return sort, nil
+	}
+	return Sort{}, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, s)
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + string(s.Field)
+	}
+	return string(s.Field)
+}
+
+// IDRange matches post ids in [Min, Max].
+type IDRange struct {
+	Min, Max int
+}
+
+// PostQuery selects a page of posts. Filters combine with AND; the values of
+// a list filter combine with OR, and an empty list does not filter.
+type PostQuery struct {
+	Sources       []string
+	UserIDs       []int
+	IDRanges      []IDRange
+	IngestedSince time.Time // inclusive; zero means unbounded
+	IngestedUntil time.Time // exclusive; zero means unbounded
+	TitleContains string    // case-insensitive substring
+
+	// DocContains matches posts whose stored document contains this JSON
+	// object (Postgres @>), e.g. {"raw":{"lang":"en"}} for an upstream
+	// field kept in the raw object.
+	DocContains json.RawMessage
+
+	Sort   Sort // zero means DefaultSort
+	Limit  int
+	Offset int     // ignored when After is set
+	After  *Cursor // resume after this post; must come from the same Sort
+	QueryOptions
+}
+
+// Normalize fills in the default sort and checks q is consistent.
+func (q PostQuery) Normalize() (PostQuery, error) {
+	if q.Sort.Field == "" {
+		q.Sort = DefaultSort
+	}
+	if _, err := ParseSort(q.Sort.String()); err != nil {
+		return q, err
+	}
+	for _, r := range q.IDRanges {
+		if r.Min > r.Max {
+			return q, fmt.Errorf("%w: id range %d-%d is empty", ErrInvalidQuery, r.Min, r.Max)
+		}
+	}
+	if !q.IngestedSince.IsZero() && !q.IngestedUntil.IsZero() && !q.IngestedSince.Before(q.IngestedUntil) {
+		return q, fmt.Errorf("%w: ingested_since must be before ingested_until", ErrInvalidQuery)
+	}
+	if len(q.DocContains) > 0 {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(q.DocContains, &obj); err != nil || obj == nil {
+			return q, fmt.Errorf("%w: doc must be a JSON object", ErrInvalidQuery)
+		}
+	}
+	if q.After != nil && q.After.Sort != q.Sort.String() {
+		return q, fmt.Errorf("%w: issued for sort %q", ErrInvalidCursor, q.After.Sort)
+	}
+	return q, nil
+}
@@ -0,0 +1,256 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// RetryPolicy bounds how a RetryCollector retries transient failures.
+// Zero fields fall back to the defaults in withDefaults.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first
+	BaseDelay   time.Duration // wait before the first retry, doubled each time
+	MaxDelay    time.Duration // cap on a single wait
+	MaxElapsed  time.Duration // cap on total time across attempts and waits
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 4
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 30 * time.Second
+	}
+	if p.MaxElapsed <= 0 {
+		p.MaxElapsed = 2 * time.Minute
+	}
+	return p
+}
+
+// RetryCollector wraps a CollectorPort and retries fetches that fail with a
+// transient error, using exponential backoff with jitter and honoring
+// Retry-After on 429/503 responses.
+type RetryCollector struct {
+	Next   CollectorPort
+	Policy RetryPolicy
+
+	now    func() time.Time
+	jitter func(d time.Duration) time.Duration
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// Ensure RetryCollector implements the StreamCollector and Committer interfaces.
+var (
+	_ StreamCollector = (*RetryCollector)(nil)
+	_ Committer       = (*RetryCollector)(nil)
+)
+
+func NewRetryCollector(next CollectorPort, p RetryPolicy) *RetryCollector {
+	return &RetryCollector{
+		Next:   next,
+		Policy: p,
+		now:    time.Now,
+		jitter: equalJitter,
+		sleep:  sleepCtx,
+	}
+}
+
+func (r *RetryCollector) Fetch(ctx context.Context) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.retry(ctx, func(ctx context.Context) error {
+		var err error
+		posts, err = r.Next.Fetch(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// Stream retries the wrapped collector's stream from the beginning on a
+// transient failure; batches already handed to fn are delivered again, which
+// is safe because the store upserts by primary key. Errors returned by fn
+// are never retried.
+func (r *RetryCollector) Stream(ctx context.Context, fn func([]models.Post) error) error {
+	return r.StreamRestarting(ctx, fn, nil)
+}
+
+// StreamRestarting is Stream, calling restart (if non-nil) before every
+// retried attempt.
+func (r *RetryCollector) StreamRestarting(ctx context.Context, fn func([]models.Post) error, restart func()) error {
+	sc, ok := r.Next.(StreamCollector)
+	if !ok {
+		posts, err := r.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		return fn(posts)
+	}
+	attempts := 0
+	err := r.retry(ctx, func(ctx context.Context) error {
+		if attempts++; attempts > 1 && restart != nil {
+			restart()
+		}
+		return sc.Stream(ctx, func(batch []models.Post) error {
+			if err := fn(batch); err != nil {
+				return permanentError{err}
+			}
+			return nil
+		})
+	})
+	var perm permanentError
+	if errors.As(err, &perm) {
+		return perm.err
+	}
+	return err
+}
+
+// Commit forwards to the wrapped collector if it is a Committer.
+func (r *RetryCollector) Commit(ctx context.Context) error {
+	if c, ok := r.Next.(Committer); ok {
+		return c.Commit(ctx)
+	}
+	return nil
+}
+
+func (r *RetryCollector) retry(ctx context.Context, op func(context.Context) error) error {
+	p := r.Policy.withDefaults()
+	start := r.now()
+	delay := p.BaseDelay
+
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		retryable, after := classifyRetry(err, r.now())
+		if !retryable || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		wait := r.jitter(min(delay, p.MaxDelay))
+		if after > wait {
+			wait = after
+		}
+		if r.now().Add(wait).Sub(start) > p.MaxElapsed {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		log.Printf("retry: attempt %d failed, retrying in %s: %v", attempt, wait, err)
+		if err := r.sleep(ctx, wait); err != nil {
+			return err
+		}
+		// capped, so that many attempts cannot overflow it
+		delay = min(delay*2, p.MaxDelay)
+	}
+}
+
+// permanentError marks an error as not retryable.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// upstreamResponse is implemented by errors reporting a non-2xx upstream
+// response, such as *UpstreamError; its status and headers decide whether
+// and when to retry.
+type upstreamResponse interface {
+	error
+	response() (status int, header http.Header)
+}
+
+// classifyRetry reports whether err is worth retrying and, for throttling
+// responses, how long after now the upstream asked us to wait.
+func classifyRetry(err error, now time.Time) (bool, time.Duration) {
+	var perm permanentError
+	if errors.As(err, &perm) {
+		return false, 0
+	}
+
+	var ur upstreamResponse
+	if errors.As(err, &ur) {
+		status, header := ur.response()
+		switch status {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true, retryAfter(header, now)
+		case http.StatusRequestTimeout, http.StatusTooEarly,
+			http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return true, 0
+		}
+		return false, 0
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrBodyTooLarge) {
+		return false, 0
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true, 0
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true, 0
+	}
+	var op *net.OpError
+	if errors.As(err, &op) {
+		return true, 0
+	}
+	return false, 0
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date. It returns 0 when the header is absent or invalid.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// equalJitter returns a random duration in [d/2, d).
+func equalJitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
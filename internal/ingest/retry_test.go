@@ -0,0 +1,200 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// newTestRetryCollector records requested waits instead of sleeping.
+func newTestRetryCollector(next CollectorPort, p RetryPolicy) (*RetryCollector, *[]time.Duration) {
+	var waits []time.Duration
+	r := NewRetryCollector(next, p)
+	r.jitter = func(d time.Duration) time.Duration { return d }
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return r, &waits
+}
+
+func TestRetry_RecoversFromTransientStatus(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"userId":1,"id":1,"title":"a","body":"b"}]`))
+	}))
+	defer s.Close()
+
+	r, waits := newTestRetryCollector(NewHTTPCollector(s.URL, 2*time.Second), RetryPolicy{BaseDelay: 100 * time.Millisecond})
+	posts, err := r.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 1 || calls.Load() != 3 {
+		t.Fatalf("expected 1 post after 3 calls, got %d posts, %d calls", len(posts), calls.Load())
+	}
+	if len(*waits) != 2 || (*waits)[0] != 100*time.Millisecond || (*waits)[1] != 200*time.Millisecond {
+		t.Fatalf("expected exponential waits [100ms 200ms], got %v", *waits)
+	}
+}
+
+func TestRetry_HonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer s.Close()
+
+	r, waits := newTestRetryCollector(NewHTTPCollector(s.URL, 2*time.Second), RetryPolicy{})
+	if _, err := r.Fetch(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Fatalf("expected a single 7s wait, got %v", *waits)
+	}
+}
+
+func TestRetry_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		http.Error(w, "nope", http.StatusNotFound)
+	}))
+	defer s.Close()
+
+	r, _ := newTestRetryCollector(NewHTTPCollector(s.URL, 2*time.Second), RetryPolicy{})
+	_, err := r.Fetch(context.Background())
+	var ue *UpstreamError
+	if !errors.As(err, &ue) || ue.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected UpstreamError 404, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected no retries, got %d calls", calls.Load())
+	}
+}
+
+func TestRetry_CapsAttempts(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	r, _ := newTestRetryCollector(NewHTTPCollector(s.URL, 2*time.Second), RetryPolicy{MaxAttempts: 3})
+	if _, err := r.Fetch(context.Background()); err == nil {
+		t.Fatal("expected error after exhausting attempts")
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestRetry_CapsElapsedTime(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+
+	r, waits := newTestRetryCollector(NewHTTPCollector(s.URL, 2*time.Second), RetryPolicy{MaxElapsed: time.Minute})
+	if _, err := r.Fetch(context.Background()); err == nil {
+		t.Fatal("expected error when Retry-After exceeds the elapsed budget")
+	}
+	if calls.Load() != 1 || len(*waits) != 0 {
+		t.Fatalf("expected to give up without waiting, got %d calls, waits %v", calls.Load(), *waits)
+	}
+}
+
+func TestRetry_RetriesNetworkErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	url := s.URL
+	s.Close() // connection refused from now on
+
+	r, waits := newTestRetryCollector(NewHTTPCollector(url, time.Second), RetryPolicy{MaxAttempts: 2})
+	if _, err := r.Fetch(context.Background()); err == nil {
+		t.Fatal("expected connection error")
+	}
+	if len(*waits) != 1 {
+		t.Fatalf("expected one retry for a refused connection, got waits %v", *waits)
+	}
+}
+
+func TestRetry_StreamDoesNotRetryCallbackErrors(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`[{"userId":1,"id":1,"title":"a","body":"b"}]`))
+	}))
+	defer s.Close()
+
+	r, _ := newTestRetryCollector(NewHTTPCollector(s.URL, 2*time.Second), RetryPolicy{})
+	boom := &UpstreamError{StatusCode: http.StatusBadGateway} // retryable if it came from upstream
+	err := r.Stream(context.Background(), func([]models.Post) error { return boom })
+	if !errors.Is(err, boom) || calls.Load() != 1 {
+		t.Fatalf("expected callback error without retries, got err=%v calls=%d", err, calls.Load())
+	}
+}
+
+// failingCollector fails every fetch with err.
+type failingCollector struct{ err error }
+
+func (f failingCollector) Fetch(ctx context.Context) ([]models.Post, error) { return nil, f.err }
+
+func TestRetry_RetryAfterDateUsesClock(t *testing.T) {
+	now := time.Date(2025, 8, 17, 10, 0, 0, 0, time.UTC)
+	header := http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}}
+	r, waits := newTestRetryCollector(failingCollector{&UpstreamError{StatusCode: http.StatusServiceUnavailable, Header: header}},
+		RetryPolicy{MaxAttempts: 2, MaxElapsed: time.Hour})
+	r.now = func() time.Time { return now }
+	if _, err := r.Fetch(context.Background()); err == nil {
+		t.Fatal("expected the upstream error")
+	}
+	if len(*waits) != 1 || (*waits)[0] != 90*time.Second {
+		t.Fatalf("expected a single 90s wait, got %v", *waits)
+	}
+}
+
+func TestRetry_DelayStaysCapped(t *testing.T) {
+	now := time.Date(2025, 8, 17, 10, 0, 0, 0, time.UTC)
+	r, waits := newTestRetryCollector(failingCollector{&UpstreamError{StatusCode: http.StatusBadGateway}},
+		RetryPolicy{MaxAttempts: 100, BaseDelay: time.Second, MaxDelay: 10 * time.Second, MaxElapsed: time.Hour})
+	r.now = func() time.Time { return now } // waits take no time, so only attempts end it
+	if _, err := r.Fetch(context.Background()); err == nil {
+		t.Fatal("expected the upstream error")
+	}
+	if len(*waits) != 99 {
+		t.Fatalf("expected 99 waits, got %d", len(*waits))
+	}
+	for i, w := range *waits {
+		if w <= 0 || w > 10*time.Second {
+			t.Fatalf("wait %d is %s, want within (0, 10s]", i, w)
+		}
+	}
+}
+
+func TestRetryAfter_HTTPDate(t *testing.T) {
+	now := time.Date(2025, 8, 17, 10, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("Retry-After", now.Add(90*time.Second).Format(http.TimeFormat))
+	if got := retryAfter(h, now); got != 90*time.Second {
+		t.Fatalf("expected 90s, got %s", got)
+	}
+}
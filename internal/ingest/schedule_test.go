@@ -0,0 +1,67 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Interval(t *testing.T) {
+	for _, spec := range []string{"15m", "@every 15m", " @every 15m "} {
+		s, err := ParseSchedule(spec)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", spec, err)
+		}
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		if got := s.Next(base); !got.Equal(base.Add(15 * time.Minute)) {
+			t.Errorf("%q: next=%s", spec, got)
+		}
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "@every -1m", "@every nope", "* * *", "61 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	base := time.Date(2025, 8, 17, 10, 11, 12, 0, time.UTC) // a Sunday
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/5 * * * *", time.Date(2025, 8, 17, 10, 15, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2025, 8, 17, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2025, 8, 18, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2025, 8, 17, 12, 0, 0, 0, time.UTC)},
+		// dom and dow both restricted: either matches
+		{"0 0 20 * 1", time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tc := range cases {
+		c, err := ParseCron(tc.spec)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.spec, err)
+		}
+		if got := c.Next(base); !got.Equal(tc.want) {
+			t.Errorf("%q: want %s got %s", tc.spec, tc.want, got)
+		}
+	}
+}
+
+func TestCron_NextRespectsLocation(t *testing.T) {
+	loc := time.FixedZone("IST", 5*60*60+30*60)
+	c, err := ParseCron("0 6 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.Next(time.Date(2025, 8, 17, 7, 0, 0, 0, loc))
+	want := time.Date(2025, 8, 18, 6, 0, 0, 0, loc)
+	if !got.Equal(want) {
+		t.Fatalf("want %s got %s", want, got)
+	}
+}
@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Job is a unit of scheduled work, e.g. a call to Service.IngestOnce.
+type Job func(ctx context.Context) error
+
+// SchedulerConfig controls when and how a Job is run.
+type SchedulerConfig struct {
+	Schedule   Schedule
+	Jitter     time.Duration // random delay in [0, Jitter) added to every activation
+	Timeout    time.Duration // per-run deadline; zero means none
+	RunOnStart bool          // run once immediately when the scheduler starts
+}
+
+// Scheduler runs a Job according to a Schedule. Activations that fire while a
+// previous run is still in flight are skipped rather than queued.
+type Scheduler struct {
+	name string
+	job  Job
+	cfg  SchedulerConfig
+
+	now    func() time.Time
+	jitter func(max time.Duration) time.Duration
+	timer  func(d time.Duration) (<-chan time.Time, func() bool)
+
+	running atomic.Bool
+	wg      sync.WaitGroup
+}
+
+func NewScheduler(name string, job Job, cfg SchedulerConfig) *Scheduler {
+	return &Scheduler{
+		name:   name,
+		job:    job,
+		cfg:    cfg,
+		now:    time.Now,
+		jitter: randomJitter,
+		timer:  newTimer,
+	}
+}
+
+func newTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
+
+// Run blocks until ctx is cancelled, triggering the job on every activation.
+// On cancellation the in-flight run (if any) sees a cancelled context and Run
+// waits for it to return before returning ctx.Err().
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.wg.Wait()
+
+	if s.cfg.RunOnStart {
+		s.trigger(ctx)
+	}
+
+	// next is tracked without jitter so the jitter does not accumulate as drift.
+	last := s.now()
+	for {
+		next := s.cfg.Schedule.Next(last)
+		if next.IsZero() {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		now := s.now()
+		if next.Before(now) {
+			// we fell behind (e.g. host suspend); resume from the present
+			next = s.cfg.Schedule.Next(now)
+			if next.IsZero() {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+		}
+		last = next
+
+		fired, stop := s.timer(next.Sub(now) + s.jitter(s.cfg.Jitter))
+		select {
+		case <-ctx.Done():
+			stop()
+			return ctx.Err()
+		case <-fired:
+			s.trigger(ctx)
+		}
+	}
+}
+
+// Running reports whether a run is currently in flight.
+func (s *Scheduler) Running() bool {
+	return s.running.Load()
+}
+
+func (s *Scheduler) trigger(ctx context.Context) {
+	if !s.running.CompareAndSwap(false, true) {
+		log.Printf("scheduler %s: previous run still in progress, skipping", s.name)
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.running.Store(false)
+
+		runCtx, cancel := ctx, context.CancelFunc(func() {})
+		if s.cfg.Timeout > 0 {
+			runCtx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		}
+		defer cancel()
+
+		start := s.now()
+		if err := s.job(runCtx); err != nil {
+			log.Printf("scheduler %s: run failed after %s: %v", s.name, s.now().Sub(start), err)
+		}
+	}()
+}
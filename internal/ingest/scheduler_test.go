@@ -0,0 +1,193 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock drives a Scheduler by hand: armed receives the delay of every
+// timer the scheduler waits on, and tick fires it and advances the clock.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	due   time.Time
+	armed chan time.Duration
+	fire  chan time.Time
+}
+
+func newFakeClock(s *Scheduler) *fakeClock {
+	c := &fakeClock{
+		now:   time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC),
+		armed: make(chan time.Duration),
+		fire:  make(chan time.Time),
+	}
+	s.now = c.Now
+	s.timer = c.timer
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) timer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mu.Lock()
+	c.due = c.now.Add(d)
+	c.mu.Unlock()
+	c.armed <- d
+	return c.fire, func() bool { return true }
+}
+
+// tick fires the pending timer and waits until the scheduler has handled
+// the activation and armed its next timer.
+func (c *fakeClock) tick(t *testing.T) {
+	t.Helper()
+	c.mu.Lock()
+	c.now = c.due
+	c.mu.Unlock()
+	c.fire <- c.now
+	c.wait(t)
+}
+
+// wait returns the delay of the next timer the scheduler arms.
+func (c *fakeClock) wait(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case d := <-c.armed:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not arm a timer")
+		return 0
+	}
+}
+
+// waitIdle waits for the in-flight run of s, if any, to return.
+func waitIdle(t *testing.T, s *Scheduler) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Running() {
+		if time.Now().After(deadline) {
+			t.Fatal("run did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// runScheduler starts s and returns a function that cancels it and returns
+// the error of Run.
+func runScheduler(s *Scheduler) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	return func() error {
+		cancel()
+		return <-done
+	}
+}
+
+func TestScheduler_RunsOnIntervalAndStops(t *testing.T) {
+	var runs atomic.Int32
+	s := NewScheduler("test", func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, SchedulerConfig{Schedule: IntervalSchedule{Interval: 20 * time.Millisecond}, RunOnStart: true})
+	clock := newFakeClock(s)
+	stop := runScheduler(s)
+
+	if d := clock.wait(t); d != 20*time.Millisecond {
+		t.Fatalf("first activation in %s, want the interval", d)
+	}
+	for range 2 {
+		waitIdle(t, s)
+		clock.tick(t)
+	}
+	if err := stop(); err != context.Canceled {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	if n := runs.Load(); n != 3 {
+		t.Fatalf("expected 3 runs, got %d", n)
+	}
+}
+
+func TestScheduler_SkipsOverlappingRuns(t *testing.T) {
+	var runs, concurrent, maxConcurrent atomic.Int32
+	release := make(chan struct{})
+	s := NewScheduler("test", func(ctx context.Context) error {
+		runs.Add(1)
+		c := concurrent.Add(1)
+		defer concurrent.Add(-1)
+		if c > maxConcurrent.Load() {
+			maxConcurrent.Store(c)
+		}
+		<-release
+		return nil
+	}, SchedulerConfig{Schedule: IntervalSchedule{Interval: 10 * time.Millisecond}, RunOnStart: true})
+	clock := newFakeClock(s)
+	stop := runScheduler(s)
+
+	// the first run is still blocked, so both activations are skipped
+	clock.wait(t)
+	clock.tick(t)
+	clock.tick(t)
+	close(release)
+	waitIdle(t, s)
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("expected overlapping activations to be skipped, got %d runs", n)
+	}
+
+	// once it returned, the next activation runs again
+	clock.tick(t)
+	_ = stop()
+	if n := runs.Load(); n != 2 {
+		t.Fatalf("expected a run after the first one finished, got %d runs", n)
+	}
+	if maxConcurrent.Load() != 1 {
+		t.Fatalf("expected runs never to overlap, max concurrent=%d", maxConcurrent.Load())
+	}
+}
+
+func TestScheduler_PerRunTimeout(t *testing.T) {
+	errs := make(chan error, 1)
+	s := NewScheduler("test", func(ctx context.Context) error {
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return ctx.Err()
+	}, SchedulerConfig{Schedule: IntervalSchedule{Interval: time.Hour}, Timeout: 20 * time.Millisecond, RunOnStart: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() { _ = s.Run(ctx) }()
+
+	select {
+	case err := <-errs:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected run deadline, got %v", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("run was not cancelled by per-run timeout")
+	}
+}
+
+func TestScheduler_JitterDelaysActivation(t *testing.T) {
+	var runs atomic.Int32
+	s := NewScheduler("test", func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, SchedulerConfig{Schedule: IntervalSchedule{Interval: 10 * time.Millisecond}, Jitter: time.Second})
+	s.jitter = func(max time.Duration) time.Duration { return max }
+	clock := newFakeClock(s)
+	stop := runScheduler(s)
+
+	if d := clock.wait(t); d != 10*time.Millisecond+time.Second {
+		t.Fatalf("first activation in %s, want the interval plus the jitter", d)
+	}
+	_ = stop()
+	if n := runs.Load(); n != 0 {
+		t.Fatalf("expected jitter to postpone the first run, got %d runs", n)
+	}
+}
@@ -1,44 +1,545 @@
 package ingest
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/renix-codex/ingestor/internal/models"
 )
 
+var (
+	// ErrUnknownSource is returned for a source name that is not registered.
+	ErrUnknownSource = errors.New("unknown source")
+	// ErrRunInProgress is returned when a source is already being ingested.
+	ErrRunInProgress = errors.New("ingestion already in progress")
+)
+
 type Service struct {
-	store     StorePort
+	store StorePort
+	now   func() time.Time
+
+	maxReconcilePosts int // MaxReconcilePosts, lowered by tests
+
+	mu      sync.Mutex
+	sources map[string]*sourceState
+	order   []string // registration order, for stable listings
+
+	// background runs started with StartSource
+	bg       sync.WaitGroup
+	bgCtx    context.Context
+	bgCancel context.CancelFunc
+}
+
+// SourceOptions tunes how a registered source is ingested.
+type SourceOptions struct {
+	Timeout time.Duration // deadline for runs started with StartSource; 0 means none
+
+	// Reconcile treats every successful fetch as a full snapshot of the
+	// source: stored posts of the source missing from it are tombstoned.
+	Reconcile bool
+	// MaxDeleteFraction aborts reconciliation if more than this fraction of
+	// the source's live posts would be tombstoned at once; 0 allows no
+	// deletions and nil means DefaultMaxDeleteFraction.
+	MaxDeleteFraction *float64
+}
+
+// DefaultMaxDeleteFraction is the reconciliation safety threshold used when
+// a source does not set one.
+const DefaultMaxDeleteFraction = 0.1
+
+// staleRunGrace is how much longer than a source's Timeout a run may stay
+// recorded as running before it is taken for one whose process stopped.
+const staleRunGrace = time.Minute
+
+// staleBefore returns the start time before which a run of the source still
+// recorded as running cannot be alive anymore, or zero if runs have no
+// deadline.
+func (o SourceOptions) staleBefore(now time.Time) time.Time {
+	if o.Timeout <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-o.Timeout - staleRunGrace)
+}
+
+// abandonedRun is the error recorded for runs failed by FailStaleRuns.
+const abandonedRun = "abandoned: still running past the source's timeout"
+
+func (o SourceOptions) maxDeleteFraction() float64 {
+	if o.MaxDeleteFraction == nil {
+		return DefaultMaxDeleteFraction
+	}
+	return *o.MaxDeleteFraction
+}
+
+// MaxReconcilePosts bounds the distinct posts a reconciled run remembers.
+// A snapshot with more is stored but not reconciled: the run fails with
+// ErrReconcileTooLarge and nothing is tombstoned.
+const MaxReconcilePosts = 1 << 20
+
+// seenKeys collects the distinct keys of a snapshot, up to limit of them.
+type seenKeys struct {
+	limit    int
+	set      map[models.PostKey]struct{}
+	overflow bool
+}
+
+func newSeenKeys(limit int) *seenKeys {
+	return &seenKeys{limit: limit, set: map[models.PostKey]struct{}{}}
+}
+
+func (k *seenKeys) add(posts []models.Post) {
+	for _, p := range posts {
+		if k.overflow {
+			return
+		}
+		key := models.PostKey{UserID: p.UserID, ID: p.ID}
+		if _, ok := k.set[key]; ok {
+			continue
+		}
+		if len(k.set) == k.limit {
+			// the snapshot cannot be reconciled anyway; free the memory
+			k.overflow, k.set = true, nil
+			return
+		}
+		k.set[key] = struct{}{}
+	}
+}
+
+// keys returns the collected keys ordered by (userId, id).
+func (k *seenKeys) keys() []models.PostKey {
+	out := make([]models.PostKey, 0, len(k.set))
+	for key := range k.set {
+		out = append(out, key)
+	}
+	slices.SortFunc(out, func(a, b models.PostKey) int {
+		return cmp.Or(cmp.Compare(a.UserID, b.UserID), cmp.Compare(a.ID, b.ID))
+	})
+	return out
+}
+
+// sourceState is a registered upstream and its run state, guarded by Service.mu.
+type sourceState struct {
 	collector CollectorPort
-	source    string
-	now       func() time.Time
+	opts      SourceOptions
+	status    models.SourceStatus
+	pushing   chan struct{} // held by the delivery being ingested; push sources only
 }
 
-// IngestOnce fetches posts from the collector, enriches them, and stores them in the database.
-func (s *Service) IngestOnce(ctx context.Context) (int, error) {
-	posts, err := s.collector.Fetch(ctx)
+// AddSource registers a collector under name; every post it yields is
+// stored with that source name. A nil collector registers a push source,
+// which only ingests posts delivered with Push.
+func (s *Service) AddSource(name string, collector CollectorPort, opts SourceOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sources[name]; ok {
+		return fmt.Errorf("source %q already registered", name)
+	}
+	src := &sourceState{collector: collector, opts: opts, status: models.SourceStatus{Name: name}}
+	if collector == nil {
+		src.pushing = make(chan struct{}, 1)
+	}
+	s.sources[name] = src
+	s.order = append(s.order, name)
+	return nil
+}
+
+// IngestOnce ingests every registered pulled source in turn and returns how many
+// posts were inserted, updated and left unchanged in total. A failing source
+// does not stop the others; their errors are joined.
+func (s *Service) IngestOnce(ctx context.Context) (models.UpsertResult, error) {
+	var total models.UpsertResult
+	var errs []error
+	for _, name := range s.pulledNames() {
+		res, err := s.IngestSource(ctx, name)
+		total.Add(res)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// IngestSource fetches posts from the named source's collector, enriches them, and stores them in the database.
+// Collectors that support streaming are consumed batch by batch. If the upstream
+// reports no changes since the last run, nothing is written and a zero result is returned.
+// Every run is recorded in the run history.
+func (s *Service) IngestSource(ctx context.Context, name string) (models.UpsertResult, error) {
+	src, run, err := s.start(ctx, name, false)
 	if err != nil {
-		return 0, err
+		return models.UpsertResult{}, err
 	}
-	enriched := Enrich(posts, s.source, s.now)
-	if err := s.store.Upsert(ctx, enriched); err != nil {
-		return 0, err
+	_, res, err := s.run(ctx, src, src.collector, run)
+	return res, err
+}
+
+// StartSource starts a run of the named source in the background and returns
+// it as soon as it is recorded. The run is bounded by the source's Timeout
+// and cancelled by Close, not by ctx.
+func (s *Service) StartSource(ctx context.Context, name string) (models.Run, error) {
+	src, run, err := s.start(ctx, name, false)
+	if err != nil {
+		return models.Run{}, err
 	}
-	return len(enriched), nil
+	s.bg.Add(1)
+	go func() {
+		defer s.bg.Done()
+		ctx, cancel := s.backgroundContext(src.opts.Timeout)
+		defer cancel()
+		if _, _, err := s.run(ctx, src, src.collector, run); err != nil {
+			log.Printf("ingest: run %d of %s: %v", run.ID, name, err)
+		}
+	}()
+	return run, nil
 }
 
-// QueryByUser retrieves enriched posts for a specific user from the store.
-func (s *Service) QueryByUser(ctx context.Context, userID int) ([]models.EnrichedPost, error) {
-	return s.store.QueryByUser(ctx, userID)
+// StartAll starts a background run of every pulled source that is not
+// already running. Sources that could not be started are reported in the joined error.
+func (s *Service) StartAll(ctx context.Context) ([]models.Run, error) {
+	var (
+		runs []models.Run
+		errs []error
+	)
+	for _, name := range s.pulledNames() {
+		run, err := s.StartSource(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, errors.Join(errs...)
 }
 
-func (s *Service) QueryRecent(ctx context.Context, limit, offset int) ([]models.EnrichedPost, error) {
-	return s.store.QueryRecent(ctx, limit, offset)
+func (s *Service) backgroundContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(s.bgCtx, timeout)
+	}
+	return context.WithCancel(s.bgCtx)
 }
 
+// ReapAbandonedRuns marks runs still recorded as running whose source's
+// Timeout has long passed as failed: the process running them stopped before
+// recording their outcome. Sources without a Timeout are skipped. It returns
+// how many runs it marked.
+func (s *Service) ReapAbandonedRuns(ctx context.Context) (int, error) {
+	now := s.now().UTC()
+	s.mu.Lock()
+	var (
+		names   []string
+		cutoffs []time.Time
+	)
+	for _, name := range s.order {
+		if cutoff := s.sources[name].opts.staleBefore(now); !cutoff.IsZero() {
+			names = append(names, name)
+			cutoffs = append(cutoffs, cutoff)
+		}
+	}
+	s.mu.Unlock()
+
+	n := 0
+	var errs []error
+	for i, name := range names {
+		k, err := s.store.FailStaleRuns(ctx, name, cutoffs[i], abandonedRun)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		n += k
+	}
+	return n, errors.Join(errs...)
+}
+
+// Close cancels background runs and waits for them to be recorded.
+func (s *Service) Close() {
+	s.bgCancel()
+	s.bg.Wait()
+}
+
+// start marks the source as running and records the new run. push says
+// whether the run ingests pushed data rather than fetching.
+func (s *Service) start(ctx context.Context, name string, push bool) (*sourceState, models.Run, error) {
+	src, err := s.begin(name, push)
+	if err != nil {
+		return nil, models.Run{}, err
+	}
+	run := models.Run{Source: name, Status: models.RunRunning, StartedAt: s.now().UTC()}
+	if run.ID, err = s.store.CreateRun(ctx, run); err != nil {
+		run.Status, run.Error = models.RunFailed, err.Error()
+		s.finish(src, run)
+		return nil, models.Run{}, err
+	}
+	s.setRunID(src, run.ID)
+	return src, run, nil
+}
+
+// run ingests what collector yields for src and records and returns the
+// outcome of run. For reconciled sources a complete, successful fetch is
+// followed by tombstoning the posts it lacked.
+func (s *Service) run(ctx context.Context, src *sourceState, collector CollectorPort, run models.Run) (models.Run, models.UpsertResult, error) {
+	var seen *seenKeys
+	if src.opts.Reconcile {
+		seen = newSeenKeys(s.maxReconcilePosts)
+	}
+	n, res, err := s.ingest(ctx, run.Source, collector, seen)
+	run.Status = models.RunSucceeded
+	if errors.Is(err, ErrNotModified) {
+		err = nil
+		run.Status = models.RunNotModified
+	} else if err == nil {
+		switch {
+		case seen == nil:
+		case seen.overflow:
+			err = fmt.Errorf("%w: more than %d posts", ErrReconcileTooLarge, seen.limit)
+		default:
+			run.Deleted, err = s.store.Tombstone(ctx, TombstoneRequest{
+				Source:      run.Source,
+				Seen:        seen.keys(),
+				At:          s.now().UTC(),
+				MaxFraction: src.opts.maxDeleteFraction(),
+			})
+		}
+		if c, ok := collector.(Committer); ok && err == nil {
+			err = c.Commit(ctx)
+		}
+	}
+	if err != nil {
+		run.Status = models.RunFailed
+		run.Error = err.Error()
+	}
+	run.Fetched, run.Inserted, run.Updated, run.Unchanged = n, res.Inserted, res.Updated, res.Unchanged
+	run.Rejected = len(res.Rejected)
+	logRejected(run.Source, res.Rejected)
+	run = s.finish(src, run)
+	return run, res, err
+}
+
+// maxLoggedRejections caps the rejected posts logged per run.
+const maxLoggedRejections = 10
+
+func logRejected(source string, rejected []models.RejectedPost) {
+	for i, r := range rejected {
+		if i == maxLoggedRejections {
+			log.Printf("ingest: %s: %d more posts rejected", source, len(rejected)-i)
+			return
+		}
+		log.Printf("ingest: %s: rejected post userId=%d id=%d: %s", source, r.UserID, r.ID, r.Error)
+	}
+}
+
+// begin marks the source as running, refusing overlapping runs and runs of
+// the wrong kind: push sources are the ones without a collector.
+func (s *Service) begin(name string, push bool) (*sourceState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, ok := s.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSource, name)
+	}
+	if isPush := src.collector == nil; push != isPush {
+		if push {
+			return nil, fmt.Errorf("%w: %q does not accept pushed data", ErrUnknownSource, name)
+		}
+		return nil, fmt.Errorf("%w: %q", ErrPushOnly, name)
+	}
+	if src.status.Running {
+		return nil, fmt.Errorf("%w: %q", ErrRunInProgress, name)
+	}
+	now := s.now().UTC()
+	src.status.Running = true
+	src.status.LastStartedAt = &now
+	return src, nil
+}
+
+func (s *Service) setRunID(src *sourceState, id int64) {
+	s.mu.Lock()
+	src.status.LastRunID = id
+	s.mu.Unlock()
+}
+
+// finish records the run outcome in the history (if the run was created)
+// and in the in-memory source status, and returns the finished run.
+func (s *Service) finish(src *sourceState, run models.Run) models.Run {
+	now := s.now().UTC()
+	if run.ID != 0 {
+		run.FinishedAt = &now
+		// the run context may already be cancelled or past its deadline
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.store.FinishRun(ctx, run); err != nil {
+			log.Printf("ingest: recording run %d of %s: %v", run.ID, run.Source, err)
+		}
+		cancel()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := &src.status
+	st.Running = false
+	st.LastFinishedAt = &now
+	st.LastCount = run.Fetched
+	st.Runs++
+	if run.Status == models.RunFailed {
+		st.LastError = run.Error
+		st.Failures++
+		return run
+	}
+	st.LastError = ""
+	st.LastSuccessAt = &now
+	return run
+}
+
+// ingest streams or fetches the source and upserts it, returning the number
+// of posts fetched and how the store absorbed them. If seen is non-nil the
+// key of every stored post is added to it.
+func (s *Service) ingest(ctx context.Context, name string, collector CollectorPort, seen *seenKeys) (int, models.UpsertResult, error) {
+	var total models.UpsertResult
+	n := 0
+	write := func(posts []models.Post) error {
+		res, err := s.store.Upsert(ctx, Enrich(posts, name, s.now))
+		total.Add(res)
+		if err != nil {
+			return err
+		}
+		n += len(posts)
+		if seen != nil {
+			seen.add(posts)
+		}
+		return nil
+	}
+
+	if rs, ok := collector.(RestartingStream); ok {
+		// Only the last attempt counts as fetched. Posts an earlier attempt
+		// wrote are unchanged when delivered again, so they are counted as
+		// inserted or updated once and taken out of Unchanged.
+		var written models.UpsertResult
+		restart := func() {
+			written.Inserted += total.Inserted
+			written.Updated += total.Updated
+			total, n = models.UpsertResult{}, 0
+		}
+		err := rs.StreamRestarting(ctx, write, restart)
+		if written.Inserted+written.Updated > 0 {
+			total.Inserted += written.Inserted
+			total.Updated += written.Updated
+			total.Unchanged = max(0, n-total.Inserted-total.Updated-len(total.Rejected)-total.Duplicates)
+		}
+		return n, total, err
+	}
+	if sc, ok := collector.(StreamCollector); ok {
+		err := sc.Stream(ctx, write)
+		return n, total, err
+	}
+
+	posts, err := collector.Fetch(ctx)
+	if err != nil {
+		return 0, total, err
+	}
+	if err := write(posts); err != nil {
+		return 0, total, err
+	}
+	return n, total, nil
+}
+
+// ListRuns returns recorded ingestion runs, newest first.
+func (s *Service) ListRuns(ctx context.Context, q RunQuery) ([]models.Run, error) {
+	return s.store.ListRuns(ctx, q)
+}
+
+// GetRun returns a single recorded run, or ErrNotFound.
+func (s *Service) GetRun(ctx context.Context, id int64) (models.Run, error) {
+	return s.store.GetRun(ctx, id)
+}
+
+// ListVersions returns every recorded revision of a post, oldest first.
+func (s *Service) ListVersions(ctx context.Context, userID, id int) ([]models.PostVersion, error) {
+	return s.store.ListVersions(ctx, userID, id)
+}
+
+// QueryAsOf returns the posts as they were at q.At.
+func (s *Service) QueryAsOf(ctx context.Context, q AsOfQuery) ([]models.PostVersion, error) {
+	return s.store.QueryAsOf(ctx, q)
+}
+
+// Sources returns the status of every registered source in registration order.
+func (s *Service) Sources() []models.SourceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]models.SourceStatus, 0, len(s.order))
+	for _, name := range s.order {
+		out = append(out, s.sources[name].status)
+	}
+	return out
+}
+
+// pulledNames returns the sources with a collector in registration order.
+func (s *Service) pulledNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, name := range s.order {
+		if s.sources[name].collector != nil {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// GetPost returns one post, tombstoned or not, or ErrNotFound.
+func (s *Service) GetPost(ctx context.Context, key models.PostKey) (models.EnrichedPost, error) {
+	return s.store.GetPost(ctx, key)
+}
+
+// QueryPosts returns one page of the posts matching q.
+func (s *Service) QueryPosts(ctx context.Context, q PostQuery) (PostPage, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return PostPage{}, err
+	}
+	return s.store.QueryPosts(ctx, q)
+}
+
+// ExportPosts streams every post matching q to fn; Limit caps the number of
+// posts only if positive.
+func (s *Service) ExportPosts(ctx context.Context, q PostQuery, fn func(models.EnrichedPost) error) error {
+	q, err := q.Normalize()
+	if err != nil {
+		return err
+	}
+	return s.store.ExportPosts(ctx, q, fn)
+}
+
+// SearchPosts runs a full-text search. An empty text fails with
+// ErrInvalidQuery.
+func (s *Service) SearchPosts(ctx context.Context, q SearchQuery) ([]models.SearchHit, error) {
+	if strings.TrimSpace(q.Text) == "" {
+		return nil, fmt.Errorf("%w: empty search", ErrInvalidQuery)
+	}
+	if q.After != nil {
+		return nil, fmt.Errorf("%w: search results are paged by offset", ErrInvalidQuery)
+	}
+	pq, err := q.PostQuery.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	q.PostQuery = pq
+	return s.store.SearchPosts(ctx, q)
+}
+
+// New creates the service. If collector is non-nil it is registered as the
+// source named source; further sources can be added with AddSource.
 func New(store StorePort, collector CollectorPort, source string, now func() time.Time) *Service {
 	if now == nil {
 		now = time.Now
 	}
-	return &Service{store: store, collector: collector, source: source, now: now}
+	s := &Service{store: store, now: now, sources: map[string]*sourceState{}, maxReconcilePosts: MaxReconcilePosts}
+	s.bgCtx, s.bgCancel = context.WithCancel(context.Background())
+	if collector != nil {
+		_ = s.AddSource(source, collector, SourceOptions{})
+	}
+	return s
 }
@@ -3,62 +3,119 @@ package ingest
 import (
 	"context"
 	"errors"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/renix-codex/ingestor/internal/models"
 )
 
-// Assumes you have:
-//   type Service struct { ... }
-//   func New(store StorePort, collector CollectorPort, source string, now func() time.Time) *Service
-// and interfaces in this package:
-//   type CollectorPort interface { Fetch(ctx context.Context) ([]models.Post, error) }
-//   type StorePort interface {
-//       Upsert(ctx context.Context, items []models.EnrichedPost) error
-//       QueryByUser(ctx context.Context, userID int) ([]models.EnrichedPost, error)
-//   }
-
 type fakeCollectorOK struct{ items []models.Post }
 
 func (f fakeCollectorOK) Fetch(ctx context.Context) ([]models.Post, error) { return f.items, nil }
 
+// fakeStreamCollector hands its items over in fixed-size batches.
+type fakeStreamCollector struct {
+	items []models.Post
+	size  int
+}
+
+func (f fakeStreamCollector) Fetch(ctx context.Context) ([]models.Post, error) { return f.items, nil }
+
+func (f fakeStreamCollector) Stream(ctx context.Context, fn func([]models.Post) error) error {
+	for i := 0; i < len(f.items); i += f.size {
+		if err := fn(f.items[i:min(i+f.size, len(f.items))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type fakeCollectorErr struct{}
 
 func (fakeCollectorErr) Fetch(ctx context.Context) ([]models.Post, error) {
 	return nil, errors.New("upstream down")
 }
 
-type fakeStoreOK struct{ saved int }
+// noHistory satisfies the run history, revision history, idempotency and tombstoning
+// parts of StorePort without recording anything.
+type noHistory struct{}
 
-func (f *fakeStoreOK) Upsert(ctx context.Context, items []models.EnrichedPost) error {
-	f.saved = len(items)
-	return nil
+func (noHistory) CreateRun(ctx context.Context, run models.Run) (int64, error) { return 1, nil }
+func (noHistory) FinishRun(ctx context.Context, run models.Run) error          { return nil }
+func (noHistory) ListRuns(ctx context.Context, q RunQuery) ([]models.Run, error) {
+	return nil, nil
 }
-func (f *fakeStoreOK) QueryByUser(ctx context.Context, userID int) ([]models.EnrichedPost, error) {
-	return []models.EnrichedPost{{
-		UserID: userID, ID: 99, Title: "t", Body: "b",
-		IngestedAt: time.Now().UTC(), Source: "src",
-	}}, nil
+func (noHistory) GetRun(ctx context.Context, id int64) (models.Run, error) {
+	return models.Run{}, ErrNotFound
 }
-
-// implement the exact signature expected by StorePort
-func (f *fakeStoreOK) QueryRecent(ctx context.Context, limit, offset int) ([]models.EnrichedPost, error) {
-	return []models.EnrichedPost{}, nil
+func (noHistory) FailStaleRuns(ctx context.Context, source string, startedBefore time.Time, reason string) (int, error) {
+	return 0, nil
+}
+func (noHistory) Tombstone(ctx context.Context, req TombstoneRequest) (int, error) {
+	return 0, nil
+}
+func (noHistory) ListVersions(ctx context.Context, userID, id int) ([]models.PostVersion, error) {
+	return nil, nil
+}
+func (noHistory) QueryAsOf(ctx context.Context, q AsOfQuery) ([]models.PostVersion, error) {
+	return nil, nil
+}
+func (noHistory) LookupIdempotencyKey(ctx context.Context, source, key string) (IdempotencyRecord, error) {
+	return IdempotencyRecord{}, ErrNotFound
+}
+func (noHistory) ClaimIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (IdempotencyRecord, error) {
+	return rec, nil
 }
 
-type fakeStoreFail struct{}
+type fakeStoreOK struct {
+	noHistory
+	saved, calls int
+	queries      []PostQuery
+}
 
-func (fakeStoreFail) Upsert(ctx context.Context, items []models.EnrichedPost) error {
-	return errors.New("db write failed")
+func (f *fakeStoreOK) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	f.saved += len(items)
+	f.calls++
+	return models.UpsertResult{Inserted: len(items)}, nil
 }
-func (fakeStoreFail) QueryByUser(ctx context.Context, userID int) ([]models.EnrichedPost, error) {
-	return nil, errors.New("db read failed")
+func (f *fakeStoreOK) QueryPosts(ctx context.Context, q PostQuery) (PostPage, error) {
+	f.queries = append(f.queries, q)
+	return PostPage{Items: []models.EnrichedPost{{
+		UserID: 1, ID: 99, Title: "t", Body: "b",
+		IngestedAt: time.Now().UTC(), Source: "src",
+	}}}, nil
+}
+func (f *fakeStoreOK) ExportPosts(ctx context.Context, q PostQuery, fn func(models.EnrichedPost) error) error {
+	f.queries = append(f.queries, q)
+	return nil
+}
+func (f *fakeStoreOK) GetPost(ctx context.Context, key models.PostKey) (models.EnrichedPost, error) {
+	return models.EnrichedPost{}, ErrNotFound
 }
+func (f *fakeStoreOK) SearchPosts(ctx context.Context, q SearchQuery) ([]models.SearchHit, error) {
+	f.queries = append(f.queries, q.PostQuery)
+	return nil, nil
+}
+
+type fakeStoreFail struct{ noHistory }
 
-// implement the exact signature expected by StorePort
-func (fakeStoreFail) QueryRecent(ctx context.Context, limit, offset int) ([]models.EnrichedPost, error) {
-	return nil, errors.New("db recent read failed")
+func (fakeStoreFail) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	return models.UpsertResult{}, errors.New("db write failed")
+}
+func (fakeStoreFail) QueryPosts(ctx context.Context, q PostQuery) (PostPage, error) {
+	return PostPage{}, errors.New("db read failed")
+}
+func (fakeStoreFail) ExportPosts(ctx context.Context, q PostQuery, fn func(models.EnrichedPost) error) error {
+	return errors.New("db read failed")
+}
+func (fakeStoreFail) GetPost(ctx context.Context, key models.PostKey) (models.EnrichedPost, error) {
+	return models.EnrichedPost{}, errors.New("db read failed")
+}
+func (fakeStoreFail) SearchPosts(ctx context.Context, q SearchQuery) ([]models.SearchHit, error) {
+	return nil, errors.New("db read failed")
 }
 
 func TestService_IngestOnce_Success(t *testing.T) {
@@ -66,12 +123,12 @@ func TestService_IngestOnce_Success(t *testing.T) {
 	col := fakeCollectorOK{items: []models.Post{{UserID: 1, ID: 1, Title: "T", Body: "B"}}}
 	svc := New(store, col, "src", func() time.Time { return time.Unix(1_720_000_000, 0) })
 
-	n, err := svc.IngestOnce(context.Background())
+	res, err := svc.IngestOnce(context.Background())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if n != 1 || store.saved != 1 {
-		t.Fatalf("expected 1 saved item, got n=%d saved=%d", n, store.saved)
+	if res.Inserted != 1 || store.saved != 1 {
+		t.Fatalf("expected 1 saved item, got res=%+v saved=%d", res, store.saved)
 	}
 }
 
@@ -95,12 +152,590 @@ func TestService_IngestOnce_CollectorError(t *testing.T) {
 	}
 }
 
-func TestService_QueryByUser_DBError(t *testing.T) {
+func TestService_QueryPosts_DBError(t *testing.T) {
 	store := fakeStoreFail{}
 	col := fakeCollectorOK{}
 	svc := New(store, col, "src", time.Now)
 
-	if _, err := svc.QueryByUser(context.Background(), 1); err == nil {
+	if _, err := svc.QueryPosts(context.Background(), PostQuery{UserIDs: []int{1}}); err == nil {
 		t.Fatalf("expected db read error, got nil")
 	}
 }
+
+func TestService_QueryPosts_Validates(t *testing.T) {
+	store := &fakeStoreOK{}
+	svc := New(store, nil, "", time.Now)
+	ctx := context.Background()
+
+	if _, err := svc.QueryPosts(ctx, PostQuery{}); err != nil {
+		t.Fatalf("QueryPosts: %v", err)
+	}
+	if got := store.queries[0].Sort; got != DefaultSort {
+		t.Fatalf("expected the default sort, got %+v", got)
+	}
+
+	c := NewCursor(DefaultSort, models.EnrichedPost{UserID: 1, ID: 2, IngestedAt: time.Now()})
+	for name, q := range map[string]PostQuery{
+		"sort":        {Sort: Sort{Field: "body"}},
+		"id range":    {IDRanges: []IDRange{{Min: 5, Max: 1}}},
+		"since/until": {IngestedSince: time.Unix(2, 0), IngestedUntil: time.Unix(1, 0)},
+		"cursor sort": {Sort: Sort{Field: SortTitle}, After: &c},
+		"doc array":   {DocContains: []byte(`[1]`)},
+		"doc invalid": {DocContains: []byte(`{"raw":`)},
+	} {
+		if _, err := svc.QueryPosts(ctx, q); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: want ErrInvalidQuery, got %v", name, err)
+		}
+	}
+	if len(store.queries) != 1 {
+		t.Fatalf("invalid queries must not reach the store, got %d calls", len(store.queries))
+	}
+}
+
+func TestService_SearchPosts_Validates(t *testing.T) {
+	store := &fakeStoreOK{}
+	svc := New(store, nil, "", time.Now)
+	ctx := context.Background()
+
+	c := NewCursor(DefaultSort, models.EnrichedPost{IngestedAt: time.Now()})
+	for name, q := range map[string]SearchQuery{
+		"empty":    {Text: "  "},
+		"cursor":   {Text: "x", PostQuery: PostQuery{After: &c}},
+		"id range": {Text: "x", PostQuery: PostQuery{IDRanges: []IDRange{{Min: 2, Max: 1}}}},
+	} {
+		if _, err := svc.SearchPosts(ctx, q); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: want ErrInvalidQuery, got %v", name, err)
+		}
+	}
+	if _, err := svc.SearchPosts(ctx, SearchQuery{Text: "hello", PostQuery: PostQuery{Sources: []string{"a"}}}); err != nil {
+		t.Fatalf("SearchPosts: %v", err)
+	}
+	if len(store.queries) != 1 || store.queries[0].Sources[0] != "a" {
+		t.Fatalf("expected one search with the filters passed on, got %+v", store.queries)
+	}
+}
+
+func TestService_IngestOnce_Streaming(t *testing.T) {
+	store := &fakeStoreOK{}
+	items := make([]models.Post, 5)
+	for i := range items {
+		items[i] = models.Post{UserID: 1, ID: i + 1, Title: "T", Body: "B"}
+	}
+	svc := New(store, fakeStreamCollector{items: items, size: 2}, "src", time.Now)
+
+	res, err := svc.IngestOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Inserted != 5 || store.saved != 5 || store.calls != 3 {
+		t.Fatalf("expected 5 items in 3 upserts, got res=%+v saved=%d calls=%d", res, store.saved, store.calls)
+	}
+}
+
+// fakeCommitCollector fails with err (if any) and records Commit calls.
+type fakeCommitCollector struct {
+	items     []models.Post
+	err       error
+	committed int
+}
+
+func (f *fakeCommitCollector) Fetch(ctx context.Context) ([]models.Post, error) {
+	return f.items, f.err
+}
+
+func (f *fakeCommitCollector) Commit(ctx context.Context) error {
+	f.committed++
+	return nil
+}
+
+func TestService_IngestOnce_NotModified(t *testing.T) {
+	store := &fakeStoreOK{}
+	col := &fakeCommitCollector{err: ErrNotModified}
+	svc := New(store, col, "src", time.Now)
+
+	res, err := svc.IngestOnce(context.Background())
+	if err != nil || !reflect.DeepEqual(res, models.UpsertResult{}) {
+		t.Fatalf("expected no-op run, got res=%+v err=%v", res, err)
+	}
+	if store.calls != 0 || col.committed != 0 {
+		t.Fatalf("expected no writes and no commit, got calls=%d committed=%d", store.calls, col.committed)
+	}
+}
+
+func TestService_IngestOnce_CommitsOnlyAfterStore(t *testing.T) {
+	col := &fakeCommitCollector{items: []models.Post{{UserID: 1, ID: 1}}}
+	if _, err := New(fakeStoreFail{}, col, "src", time.Now).IngestOnce(context.Background()); err == nil {
+		t.Fatal("expected db error")
+	}
+	if col.committed != 0 {
+		t.Fatal("collector committed despite failed write")
+	}
+
+	if _, err := New(&fakeStoreOK{}, col, "src", time.Now).IngestOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if col.committed != 1 {
+		t.Fatalf("expected one commit, got %d", col.committed)
+	}
+}
+
+// recordingStore remembers every upserted post.
+type recordingStore struct {
+	fakeStoreOK
+	items []models.EnrichedPost
+}
+
+func (r *recordingStore) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	r.items = append(r.items, items...)
+	return models.UpsertResult{Inserted: len(items)}, nil
+}
+
+func TestService_MultiSource(t *testing.T) {
+	store := &recordingStore{}
+	svc := New(store, nil, "", time.Now)
+	if err := svc.AddSource("a", fakeCollectorOK{items: []models.Post{{UserID: 1, ID: 1}}}, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddSource("b", fakeCollectorErr{}, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddSource("c", fakeCollectorOK{items: []models.Post{{UserID: 2, ID: 2}, {UserID: 2, ID: 3}}}, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddSource("a", fakeCollectorOK{}, SourceOptions{}); err == nil {
+		t.Fatal("expected duplicate source to be rejected")
+	}
+
+	res, err := svc.IngestOnce(context.Background())
+	if err == nil {
+		t.Fatal("expected source b's error to be reported")
+	}
+	if res.Inserted != 3 || len(store.items) != 3 {
+		t.Fatalf("expected the healthy sources to be ingested, got res=%+v stored=%d", res, len(store.items))
+	}
+	if store.items[0].Source != "a" || store.items[2].Source != "c" {
+		t.Fatalf("posts not tagged with their source: %+v", store.items)
+	}
+
+	st := svc.Sources()
+	if len(st) != 3 || st[0].Name != "a" || st[1].Name != "b" {
+		t.Fatalf("unexpected statuses %+v", st)
+	}
+	if st[0].LastSuccessAt == nil || st[0].LastCount != 1 || st[0].Running {
+		t.Errorf("unexpected status for a: %+v", st[0])
+	}
+	if st[1].Failures != 1 || st[1].LastError == "" || st[1].LastSuccessAt != nil {
+		t.Errorf("unexpected status for b: %+v", st[1])
+	}
+}
+
+// blockingCollector blocks until release is closed.
+type blockingCollector struct{ started, release chan struct{} }
+
+func (b blockingCollector) Fetch(ctx context.Context) ([]models.Post, error) {
+	close(b.started)
+	<-b.release
+	return nil, nil
+}
+
+func TestService_IngestSource_RefusesOverlap(t *testing.T) {
+	col := blockingCollector{started: make(chan struct{}), release: make(chan struct{})}
+	svc := New(&fakeStoreOK{}, col, "src", time.Now)
+
+	done := make(chan error)
+	go func() {
+		_, err := svc.IngestSource(context.Background(), "src")
+		done <- err
+	}()
+	<-col.started
+
+	if _, err := svc.IngestSource(context.Background(), "src"); !errors.Is(err, ErrRunInProgress) {
+		t.Fatalf("expected ErrRunInProgress, got %v", err)
+	}
+	if !svc.Sources()[0].Running {
+		t.Fatal("expected source to be reported as running")
+	}
+	close(col.release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.IngestSource(context.Background(), "nope"); !errors.Is(err, ErrUnknownSource) {
+		t.Fatalf("expected ErrUnknownSource, got %v", err)
+	}
+}
+
+// runStore keeps run history in memory and reports every other post as updated.
+type runStore struct {
+	fakeStoreOK
+	runs []models.Run
+}
+
+func (r *runStore) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	var res models.UpsertResult
+	for i := range items {
+		if i%2 == 0 {
+			res.Inserted++
+		} else {
+			res.Updated++
+		}
+	}
+	return res, nil
+}
+
+func (r *runStore) CreateRun(ctx context.Context, run models.Run) (int64, error) {
+	run.ID = int64(len(r.runs) + 1)
+	r.runs = append(r.runs, run)
+	return run.ID, nil
+}
+
+func (r *runStore) FinishRun(ctx context.Context, run models.Run) error {
+	r.runs[run.ID-1] = run
+	return nil
+}
+
+func (r *runStore) FailStaleRuns(ctx context.Context, source string, startedBefore time.Time, reason string) (int, error) {
+	n := 0
+	for i, run := range r.runs {
+		if run.Source == source && run.Status == models.RunRunning && run.StartedAt.Before(startedBefore) {
+			r.runs[i].Status, r.runs[i].Error = models.RunFailed, reason
+			n++
+		}
+	}
+	return n, nil
+}
+
+// keyedStore inserts posts it has not seen and reports the others unchanged.
+type keyedStore struct {
+	runStore
+	keys map[models.PostKey]bool
+}
+
+func (k *keyedStore) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	var res models.UpsertResult
+	for _, it := range items {
+		key := models.PostKey{UserID: it.UserID, ID: it.ID}
+		if k.keys[key] {
+			res.Unchanged++
+			continue
+		}
+		k.keys[key] = true
+		res.Inserted++
+	}
+	return res, nil
+}
+
+// flakyStream hands over its pages, failing once before the second.
+type flakyStream struct {
+	pages  [][]models.Post
+	failed bool
+}
+
+func (f *flakyStream) Fetch(ctx context.Context) ([]models.Post, error) {
+	return nil, errors.New("not used")
+}
+
+func (f *flakyStream) Stream(ctx context.Context, fn func([]models.Post) error) error {
+	for i, page := range f.pages {
+		if i == 1 && !f.failed {
+			f.failed = true
+			return &UpstreamError{StatusCode: http.StatusBadGateway}
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestService_RetriedStreamCountsOnce(t *testing.T) {
+	col := &flakyStream{pages: [][]models.Post{
+		{{UserID: 1, ID: 1}, {UserID: 1, ID: 2}},
+		{{UserID: 1, ID: 3}},
+	}}
+	retry, waits := newTestRetryCollector(col, RetryPolicy{})
+	store := &keyedStore{keys: map[models.PostKey]bool{}}
+	svc := New(store, nil, "", time.Now)
+	if err := svc.AddSource("src", retry, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.IngestSource(context.Background(), "src"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*waits) != 1 {
+		t.Fatalf("expected one retry, got waits %v", *waits)
+	}
+	if run := store.runs[0]; run.Fetched != 3 || run.Inserted != 3 || run.Unchanged != 0 {
+		t.Fatalf("expected 3 posts fetched and inserted once, got %+v", run)
+	}
+	if n := svc.Sources()[0].LastCount; n != 3 {
+		t.Fatalf("LastCount = %d, want 3", n)
+	}
+}
+
+func TestService_ReapAbandonedRuns(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	store := &runStore{runs: []models.Run{
+		{ID: 1, Source: "a", Status: models.RunRunning, StartedAt: now.Add(-time.Hour)},
+		{ID: 2, Source: "a", Status: models.RunRunning, StartedAt: now.Add(-time.Minute)}, // may still be alive
+		{ID: 3, Source: "a", Status: models.RunSucceeded, StartedAt: now.Add(-time.Hour)},
+		{ID: 4, Source: "b", Status: models.RunRunning, StartedAt: now.Add(-time.Hour)}, // no timeout
+	}}
+	svc := New(store, nil, "", func() time.Time { return now })
+	if err := svc.AddSource("a", fakeCollectorOK{}, SourceOptions{Timeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddSource("b", fakeCollectorOK{}, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := svc.ReapAbandonedRuns(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("reaped %d, %v; want 1", n, err)
+	}
+	want := []string{models.RunFailed, models.RunRunning, models.RunSucceeded, models.RunRunning}
+	for i, run := range store.runs {
+		if run.Status != want[i] {
+			t.Errorf("run %d: status %s, want %s", run.ID, run.Status, want[i])
+		}
+	}
+}
+
+func TestService_RecordsRuns(t *testing.T) {
+	store := &runStore{}
+	svc := New(store, fakeStreamCollector{items: make([]models.Post, 5), size: 2}, "src", time.Now)
+	if err := svc.AddSource("down", fakeCollectorErr{}, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddSource("same", &fakeCommitCollector{err: ErrNotModified}, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	res, _ := svc.IngestOnce(context.Background())
+	if !reflect.DeepEqual(res, models.UpsertResult{Inserted: 3, Updated: 2}) {
+		t.Errorf("unexpected totals %+v", res)
+	}
+
+	if len(store.runs) != 3 {
+		t.Fatalf("expected 3 runs, got %d", len(store.runs))
+	}
+	ok, failed, same := store.runs[0], store.runs[1], store.runs[2]
+	if ok.Status != models.RunSucceeded || ok.Source != "src" || ok.FinishedAt == nil ||
+		ok.Fetched != 5 || ok.Inserted != 3 || ok.Updated != 2 {
+		t.Errorf("unexpected successful run %+v", ok)
+	}
+	if failed.Status != models.RunFailed || failed.Error != "upstream down" || failed.FinishedAt == nil {
+		t.Errorf("unexpected failed run %+v", failed)
+	}
+	if same.Status != models.RunNotModified || same.Error != "" {
+		t.Errorf("unexpected not-modified run %+v", same)
+	}
+	if st := svc.Sources()[0]; st.LastRunID != 1 {
+		t.Errorf("expected status to reference run 1, got %d", st.LastRunID)
+	}
+}
+
+func TestService_StartSource(t *testing.T) {
+	col := blockingCollector{started: make(chan struct{}), release: make(chan struct{})}
+	store := &runStore{}
+	svc := New(store, col, "src", time.Now)
+	if err := svc.AddSource("other", fakeCollectorOK{}, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := svc.StartSource(context.Background(), "src")
+	if err != nil || run.ID != 1 || run.Status != models.RunRunning {
+		t.Fatalf("expected running run 1, got %+v err=%v", run, err)
+	}
+	<-col.started
+	if _, err := svc.StartSource(context.Background(), "src"); !errors.Is(err, ErrRunInProgress) {
+		t.Fatalf("expected ErrRunInProgress, got %v", err)
+	}
+
+	runs, err := svc.StartAll(context.Background())
+	if !errors.Is(err, ErrRunInProgress) || len(runs) != 1 || runs[0].Source != "other" {
+		t.Fatalf("expected only the idle source to start, got %+v err=%v", runs, err)
+	}
+
+	close(col.release)
+	svc.Close()
+	if store.runs[0].Status != models.RunSucceeded || store.runs[0].FinishedAt == nil {
+		t.Fatalf("expected background run to be recorded, got %+v", store.runs[0])
+	}
+}
+
+func TestService_StartSource_Timeout(t *testing.T) {
+	store := &runStore{}
+	svc := New(store, nil, "", time.Now)
+	if err := svc.AddSource("slow", ctxCollector{}, SourceOptions{Timeout: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.StartSource(context.Background(), "slow"); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); svc.Sources()[0].Running; {
+		if time.Now().After(deadline) {
+			t.Fatal("run did not time out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	svc.Close()
+	if r := store.runs[0]; r.Status != models.RunFailed || !strings.Contains(r.Error, "deadline") {
+		t.Fatalf("expected run to fail on its deadline, got %+v", r)
+	}
+}
+
+// ctxCollector blocks until its context is done.
+type ctxCollector struct{}
+
+func (ctxCollector) Fetch(ctx context.Context) ([]models.Post, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// tombstoneStore records Tombstone requests and fails them with err.
+type tombstoneStore struct {
+	runStore
+	reqs []TombstoneRequest
+	err  error
+}
+
+func (t *tombstoneStore) Tombstone(ctx context.Context, req TombstoneRequest) (int, error) {
+	t.reqs = append(t.reqs, req)
+	if t.err != nil {
+		return 0, t.err
+	}
+	return 2, nil
+}
+
+func TestService_Reconcile(t *testing.T) {
+	posts := []models.Post{{UserID: 1, ID: 1}, {UserID: 1, ID: 2}, {UserID: 2, ID: 3}}
+	store := &tombstoneStore{}
+	svc := New(store, nil, "", time.Now)
+	if err := svc.AddSource("full", fakeStreamCollector{items: posts, size: 2}, SourceOptions{Reconcile: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddSource("partial", fakeCollectorOK{items: posts}, SourceOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddSource("same", &fakeCommitCollector{err: ErrNotModified}, SourceOptions{Reconcile: true}); err != nil {
+		t.Fatal(err)
+	}
+	half := 0.5
+	if err := svc.AddSource("down", fakeCollectorErr{}, SourceOptions{Reconcile: true, MaxDeleteFraction: &half}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = svc.IngestOnce(context.Background())
+
+	if len(store.reqs) != 1 {
+		t.Fatalf("expected only the complete snapshot to be reconciled, got %+v", store.reqs)
+	}
+	req := store.reqs[0]
+	if req.Source != "full" || len(req.Seen) != 3 || req.Seen[2] != (models.PostKey{UserID: 2, ID: 3}) {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if req.MaxFraction != DefaultMaxDeleteFraction || req.At.IsZero() {
+		t.Errorf("expected the default threshold and a deletion time, got %+v", req)
+	}
+	if store.runs[0].Deleted != 2 {
+		t.Errorf("expected the run to record 2 deletions, got %+v", store.runs[0])
+	}
+}
+
+func TestService_Reconcile_ThresholdFailsRun(t *testing.T) {
+	store := &tombstoneStore{err: ErrDeleteThreshold}
+	col := &fakeCommitCollector{}
+	svc := New(store, nil, "", time.Now)
+	fraction := 0.3
+	if err := svc.AddSource("src", col, SourceOptions{Reconcile: true, MaxDeleteFraction: &fraction}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.IngestSource(context.Background(), "src"); !errors.Is(err, ErrDeleteThreshold) {
+		t.Fatalf("expected ErrDeleteThreshold, got %v", err)
+	}
+	if store.reqs[0].MaxFraction != 0.3 {
+		t.Errorf("expected the source threshold, got %v", store.reqs[0].MaxFraction)
+	}
+	if r := store.runs[0]; r.Status != models.RunFailed || r.Deleted != 0 {
+		t.Errorf("expected a failed run without deletions, got %+v", r)
+	}
+	if col.committed != 0 {
+		t.Error("expected validators not to be committed after a failed reconciliation")
+	}
+}
+
+func TestService_Reconcile_ZeroFraction(t *testing.T) {
+	store := &tombstoneStore{}
+	svc := New(store, nil, "", time.Now)
+	none := 0.0
+	if err := svc.AddSource("src", fakeCollectorOK{items: []models.Post{{UserID: 1, ID: 1}}}, SourceOptions{Reconcile: true, MaxDeleteFraction: &none}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.IngestSource(context.Background(), "src"); err != nil {
+		t.Fatal(err)
+	}
+	if store.reqs[0].MaxFraction != 0 {
+		t.Fatalf("expected a zero threshold to allow no deletions, got %v", store.reqs[0].MaxFraction)
+	}
+}
+
+func TestService_Reconcile_SeenKeys(t *testing.T) {
+	posts := []models.Post{{UserID: 2, ID: 1}, {UserID: 1, ID: 2}, {UserID: 2, ID: 1}, {UserID: 1, ID: 1}}
+	store := &tombstoneStore{}
+	svc := New(store, nil, "", time.Now)
+	svc.maxReconcilePosts = 3
+	if err := svc.AddSource("src", fakeStreamCollector{items: posts, size: 2}, SourceOptions{Reconcile: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.IngestSource(context.Background(), "src"); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.PostKey{{UserID: 1, ID: 1}, {UserID: 1, ID: 2}, {UserID: 2, ID: 1}}
+	if !reflect.DeepEqual(store.reqs[0].Seen, want) {
+		t.Fatalf("seen %+v, want the distinct keys in order %+v", store.reqs[0].Seen, want)
+	}
+
+	svc.maxReconcilePosts = 2
+	if _, err := svc.IngestSource(context.Background(), "src"); !errors.Is(err, ErrReconcileTooLarge) {
+		t.Fatalf("expected ErrReconcileTooLarge, got %v", err)
+	}
+	if len(store.reqs) != 1 {
+		t.Fatalf("an oversized snapshot was reconciled: %+v", store.reqs[1:])
+	}
+	if r := store.runs[1]; r.Status != models.RunFailed || r.Fetched != len(posts) {
+		t.Fatalf("expected a failed run that still stored the posts, got %+v", r)
+	}
+}
+
+// rejectingStore refuses posts with an empty title.
+type rejectingStore struct{ runStore }
+
+func (r *rejectingStore) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	var res models.UpsertResult
+	for _, it := range items {
+		if it.Title == "" {
+			res.Rejected = append(res.Rejected, models.RejectedPost{UserID: it.UserID, ID: it.ID, Error: "empty title"})
+			continue
+		}
+		res.Inserted++
+	}
+	return res, nil
+}
+
+func TestService_ReportsRejectedPosts(t *testing.T) {
+	posts := []models.Post{{UserID: 1, ID: 1, Title: "a"}, {UserID: 1, ID: 2}, {UserID: 1, ID: 3, Title: "c"}, {UserID: 2, ID: 4}}
+	store := &rejectingStore{}
+	svc := New(store, fakeStreamCollector{items: posts, size: 3}, "src", time.Now)
+
+	res, err := svc.IngestSource(context.Background(), "src")
+	if err != nil {
+		t.Fatalf("rejected posts should not fail the run: %v", err)
+	}
+	want := []models.RejectedPost{{UserID: 1, ID: 2, Error: "empty title"}, {UserID: 2, ID: 4, Error: "empty title"}}
+	if res.Inserted != 2 || !reflect.DeepEqual(res.Rejected, want) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if r := store.runs[0]; r.Status != models.RunSucceeded || r.Rejected != 2 || r.Inserted != 2 {
+		t.Fatalf("unexpected run %+v", r)
+	}
+}
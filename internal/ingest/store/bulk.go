@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+const createStaging = `
+CREATE TEMP TABLE posts_staging (
+  ord INT, user_id INT, id INT, title TEXT, body TEXT, ingested_at TIMESTAMPTZ,
+  source TEXT, content_hash TEXT, doc JSONB, search_lang TEXT
+) ON COMMIT DROP`
+
+var stagingColumns = []string{"ord", "user_id", "id", "title", "body", "ingested_at", "source", "content_hash", "doc", "search_lang"}
+
+// upsertStaged merges posts_staging into posts in one statement. Upsert
+// drops duplicate keys before staging; should one slip through, its last
+// occurrence wins, as on the row-wise path.
+const upsertStaged = `
+WITH up AS (
+  INSERT INTO posts (user_id,id,title,body,ingested_at,source,content_hash,doc,search_lang)
+  SELECT DISTINCT ON (user_id, id)
+    user_id, id, title, body, ingested_at, source, content_hash, doc, search_lang::regconfig
+  FROM posts_staging
+  ORDER BY user_id, id, ord DESC` + upsertConflict + `
+),` + recordVersions + `
+SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM up`
+
+// bulkUpsert has the semantics of the row-wise Upsert but loads each chunk of
+// items with COPY into a temporary staging table and merges it with a single
+// set-based statement. In UpsertAtomic mode all chunks share one transaction,
+// so the call still writes everything or nothing. In UpsertPerRow mode every
+// chunk is its own transaction, a chunk the database refuses is retried row by
+// row, and an error leaves the earlier chunks written; the result counts them.
+func (s *PGStore) bulkUpsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	if s.UpsertMode != UpsertPerRow {
+		var res models.UpsertResult
+		err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			for _, chunk := range s.chunks(items) {
+				r, err := s.copyMerge(ctx, tx, chunk)
+				if err != nil {
+					return err
+				}
+				res.Add(r)
+			}
+			return nil
+		})
+		if err != nil {
+			// the transaction was rolled back
+			return models.UpsertResult{}, err
+		}
+		return res, nil
+	}
+
+	var res models.UpsertResult
+	for _, chunk := range s.chunks(items) {
+		var r models.UpsertResult
+		err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			var err error
+			r, err = inSavepoint(ctx, tx, func(sp pgx.Tx) (models.UpsertResult, error) {
+				return s.copyMerge(ctx, sp, chunk)
+			})
+			if isRowError(err) {
+				r, err = s.upsertEachRow(ctx, tx, chunk)
+			}
+			return err
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Add(r)
+	}
+	return res, nil
+}
+
+// useBulk reports whether an Upsert of n posts takes the bulk path.
+func (s *PGStore) useBulk(n int) bool {
+	threshold := s.BulkThreshold
+	if threshold == 0 {
+		threshold = DefaultBulkThreshold
+	}
+	return threshold > 0 && n >= threshold
+}
+
+// chunks splits items into chunks of at most BulkChunkSize posts.
+func (s *PGStore) chunks(items []models.EnrichedPost) [][]models.EnrichedPost {
+	size := s.BulkChunkSize
+	if size <= 0 {
+		size = DefaultBulkChunkSize
+	}
+	var out [][]models.EnrichedPost
+	for start := 0; start < len(items); start += size {
+		out = append(out, items[start:min(start+size, len(items))])
+	}
+	return out
+}
+
+// copyMerge stages chunk with COPY and merges it into posts. The staging
+// table is dropped again, so that further chunks can follow in the same
+// transaction.
+func (s *PGStore) copyMerge(ctx context.Context, tx pgx.Tx, chunk []models.EnrichedPost) (models.UpsertResult, error) {
+	if _, err := tx.Exec(ctx, createStaging); err != nil {
+		return models.UpsertResult{}, err
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"posts_staging"}, stagingColumns,
+		pgx.CopyFromSlice(len(chunk), func(i int) ([]any, error) {
+			return s.stagingRow(i, chunk[i])
+		})); err != nil {
+		return models.UpsertResult{}, fmt.Errorf("copy: %w", err)
+	}
+
+	var inserted, updated int
+	if err := tx.QueryRow(ctx, upsertStaged).Scan(&inserted, &updated); err != nil {
+		return models.UpsertResult{}, err
+	}
+	if _, err := tx.Exec(ctx, `DROP TABLE posts_staging`); err != nil {
+		return models.UpsertResult{}, err
+	}
+	return models.UpsertResult{
+		Inserted:  inserted,
+		Updated:   updated,
+		Unchanged: len(chunk) - inserted - updated,
+	}, nil
+}
+
+// stagingRow returns the posts_staging row, in stagingColumns order, of the
+// i-th post of a chunk.
+func (s *PGStore) stagingRow(i int, it models.EnrichedPost) ([]any, error) {
+	raw, err := json.Marshal(it)
+	if err != nil {
+		return nil, err
+	}
+	return []any{i, it.UserID, it.ID, it.Title, it.Body, it.IngestedAt, it.Source, it.ContentHash, raw,
+		s.searchLanguage(it.Source)}, nil
+}
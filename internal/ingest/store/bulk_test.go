@@ -0,0 +1,127 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+func TestUseBulk(t *testing.T) {
+	cases := []struct {
+		threshold, n int
+		want         bool
+	}{
+		{0, ingest.DefaultBatchSize, true}, // a full streaming batch
+		{0, ingest.DefaultBatchSize - 1, false},
+		{10, 10, true},
+		{10, 9, false},
+		{-1, 1 << 20, false},
+	}
+	for _, tc := range cases {
+		s := &PGStore{BulkThreshold: tc.threshold}
+		if got := s.useBulk(tc.n); got != tc.want {
+			t.Errorf("threshold %d, %d posts: useBulk = %v, want %v", tc.threshold, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestChunks(t *testing.T) {
+	items := make([]models.EnrichedPost, 5)
+	for i := range items {
+		items[i].ID = i + 1
+	}
+	s := &PGStore{BulkChunkSize: 2}
+	var sizes []int
+	next := 1
+	for _, c := range s.chunks(items) {
+		sizes = append(sizes, len(c))
+		for _, it := range c {
+			if it.ID != next {
+				t.Fatalf("post %d out of order, want %d", it.ID, next)
+			}
+			next++
+		}
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Fatalf("chunk sizes %v, want [2 2 1]", sizes)
+	}
+	if n := len((&PGStore{}).chunks(items)); n != 1 {
+		t.Fatalf("default chunk size gave %d chunks, want 1", n)
+	}
+}
+
+func TestStagingColumns(t *testing.T) {
+	defs := createStaging[strings.Index(createStaging, "(")+1 : strings.LastIndex(createStaging, ")")]
+	var cols []string
+	for _, def := range strings.Split(defs, ",") {
+		cols = append(cols, strings.Fields(def)[0])
+	}
+	if strings.Join(cols, ",") != strings.Join(stagingColumns, ",") {
+		t.Fatalf("posts_staging has columns %v, COPY writes %v", cols, stagingColumns)
+	}
+}
+
+func TestStagingRow(t *testing.T) {
+	at := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	it := models.EnrichedPost{
+		UserID: 1, ID: 2, Title: "t", Body: "b", IngestedAt: at, Source: "de", ContentHash: "h",
+		Raw: json.RawMessage(`{"id":2}`),
+	}
+	s := &PGStore{SearchLanguages: map[string]string{"de": "german"}}
+	row, err := s.stagingRow(7, it)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(row) != len(stagingColumns) {
+		t.Fatalf("row has %d values for %d columns", len(row), len(stagingColumns))
+	}
+	want := []any{7, 1, 2, "t", "b", at, "de", "h"}
+	for i, v := range want {
+		if row[i] != v {
+			t.Errorf("%s = %v, want %v", stagingColumns[i], row[i], v)
+		}
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(row[8].([]byte), &doc); err != nil || string(doc["raw"]) != `{"id":2}` {
+		t.Errorf("doc = %s (%v), want the post with its raw object", row[8], err)
+	}
+	if row[9] != "german" {
+		t.Errorf("search_lang = %v, want german", row[9])
+	}
+}
+
+func TestUpsertStaged_KeepsLastDuplicate(t *testing.T) {
+	// the row-wise path writes duplicates in order, so the last one wins
+	if !strings.Contains(upsertStaged, "DISTINCT ON (user_id, id)") ||
+		!strings.Contains(upsertStaged, "ORDER BY user_id, id, ord DESC") {
+		t.Fatalf("upsertStaged does not keep the last occurrence of a key:\n%s", upsertStaged)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	items := []models.EnrichedPost{
+		{UserID: 1, ID: 1, Title: "first"},
+		{UserID: 1, ID: 2, Title: "other"},
+		{UserID: 2, ID: 1, Title: "another user"},
+		{UserID: 1, ID: 1, Title: "second"},
+		{UserID: 1, ID: 1, Title: "last"},
+	}
+	got, dups := dedupe(items)
+	var titles []string
+	for _, it := range got {
+		titles = append(titles, it.Title)
+	}
+	// both paths write these three posts, so their counts agree
+	if dups != 2 || strings.Join(titles, ",") != "other,another user,last" {
+		t.Fatalf("dedupe kept %v and dropped %d, want [other another user last] and 2", titles, dups)
+	}
+
+	distinct := items[:3]
+	if got, dups := dedupe(distinct); dups != 0 || &got[0] != &distinct[0] {
+		t.Fatalf("distinct items were copied or dropped: %d", dups)
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/renix-codex/ingestor/internal/ingest"
+)
+
+// IdempotencyKeyTTL is how long an idempotency key of a pushed delivery is
+// honoured; later deliveries with the same key are processed again.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// LookupIdempotencyKey returns the live record of key, or ingest.ErrNotFound.
+func (s *PGStore) LookupIdempotencyKey(ctx context.Context, source, key string) (ingest.IdempotencyRecord, error) {
+	rec := ingest.IdempotencyRecord{Source: source, Key: key}
+	err := s.pool.QueryRow(ctx, `
+SELECT request_hash, run_id FROM ingest_idempotency_keys
+WHERE source=$1 AND key=$2 AND created_at > now() - $3::interval`,
+		source, key, IdempotencyKeyTTL).Scan(&rec.RequestHash, &rec.RunID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ingest.IdempotencyRecord{}, ingest.ErrNotFound
+	}
+	return rec, err
+}
+
+// ClaimIdempotencyKey stores rec unless its key is held by a live record
+// whose run has not failed, and returns the record holding the key. Expired
+// keys are purged on the way.
+func (s *PGStore) ClaimIdempotencyKey(ctx context.Context, rec ingest.IdempotencyRecord) (ingest.IdempotencyRecord, error) {
+	out := rec
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM ingest_idempotency_keys WHERE created_at <= now() - $1::interval`,
+			IdempotencyKeyTTL); err != nil {
+			return err
+		}
+		err := tx.QueryRow(ctx, `
+INSERT INTO ingest_idempotency_keys AS k (source, key, request_hash, run_id, created_at)
+VALUES ($1, $2, $3, $4, now())
+ON CONFLICT (source, key) DO UPDATE SET
+  request_hash=EXCLUDED.request_hash, run_id=EXCLUDED.run_id, created_at=EXCLUDED.created_at
+WHERE EXISTS (SELECT 1 FROM ingest_runs r WHERE r.id = k.run_id AND r.status = 'failed')
+RETURNING request_hash, run_id`,
+			rec.Source, rec.Key, rec.RequestHash, rec.RunID).Scan(&out.RequestHash, &out.RunID)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		// the key is held; a new statement sees the holder even if it
+		// committed while the insert waited for it
+		return tx.QueryRow(ctx,
+			`SELECT request_hash, run_id FROM ingest_idempotency_keys WHERE source=$1 AND key=$2`,
+			rec.Source, rec.Key).Scan(&out.RequestHash, &out.RunID)
+	})
+	if err != nil {
+		return ingest.IdempotencyRecord{}, err
+	}
+	return out, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/renix-codex/ingestor/schemas"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_col.up.sql":   {Data: []byte("ALTER TABLE t ADD c INT;")},
+		"0002_add_col.down.sql": {Data: []byte("ALTER TABLE t DROP c;")},
+		"0001_init.up.sql":      {Data: []byte("CREATE TABLE t (id INT);")},
+		"0010_seed.up.sql":      {Data: []byte("INSERT INTO t VALUES (1);")},
+		"README.md":             {Data: []byte("ignored")},
+	}
+	migs, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) != 3 {
+		t.Fatalf("expected 3 migrations, got %+v", migs)
+	}
+	if migs[0].Version != 1 || migs[1].Version != 2 || migs[2].Version != 10 {
+		t.Errorf("not ordered by version: %+v", migs)
+	}
+	if migs[1].Name != "add_col" || migs[1].Down == "" || migs[2].Down != "" {
+		t.Errorf("unexpected migrations %+v", migs)
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"down without up": {"0001_init.down.sql": {Data: []byte("DROP TABLE t;")}},
+		"name mismatch": {
+			"0001_init.up.sql":  {Data: []byte("CREATE TABLE t (id INT);")},
+			"0001_other.up.sql": {Data: []byte("CREATE TABLE u (id INT);")},
+		},
+		"version zero": {"0000_init.up.sql": {Data: []byte("SELECT 1;")}},
+	}
+	for name, fsys := range cases {
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migs, err := LoadMigrations(schemas.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migs {
+		if m.Version != int64(i+1) {
+			t.Errorf("expected contiguous versions, got %d at position %d", m.Version, i)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d (%s) has no down file", m.Version, m.Name)
+		}
+	}
+	if len(migs) == 0 || !strings.Contains(migs[0].Up, "CREATE TABLE IF NOT EXISTS posts") {
+		t.Fatalf("expected the posts table first, got %+v", migs)
+	}
+}
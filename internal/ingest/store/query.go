@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// sortColumns whitelists the columns ingest.SortField may name; the value is
+// interpolated into SQL, so it must never come from the request directly.
+var sortColumns = map[ingest.SortField]string{
+	ingest.SortIngestedAt: "ingested_at",
+	ingest.SortID:         "id",
+	ingest.SortUserID:     "user_id",
+	ingest.SortTitle:      "title",
+	ingest.SortSource:     "source",
+}
+
+// GetPost returns the post stored under key, tombstoned or not.
+func (s *PGStore) GetPost(ctx context.Context, key models.PostKey) (models.EnrichedPost, error) {
+	rows, err := s.pool.Query(ctx, `
+SELECT doc, deleted_at, ingested_at FROM posts
+WHERE user_id=$1 AND id=$2`, key.UserID, key.ID)
+	if err != nil {
+		return models.EnrichedPost{}, err
+	}
+	posts, err := collectPosts(rows)
+	if err != nil {
+		return models.EnrichedPost{}, err
+	}
+	if len(posts) == 0 {
+		return models.EnrichedPost{}, ingest.ErrNotFound
+	}
+	return posts[0], nil
+}
+
+// QueryPosts returns one page of the posts matching q. Every filter is a
+// predicate on a typed column; one row past the limit is read to tell
+// whether there is a next page.
+func (s *PGStore) QueryPosts(ctx context.Context, q ingest.PostQuery) (ingest.PostPage, error) {
+	// sane limits
+	limit, offset := q.Limit, q.Offset
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	if offset < 0 || q.After != nil {
+		offset = 0
+	}
+	if q.Sort.Field == "" {
+		q.Sort = ingest.DefaultSort
+	}
+
+	w, sql, err := selectPosts(q)
+	if err != nil {
+		return ingest.PostPage{}, err
+	}
+	sql += fmt.Sprintf("\nLIMIT %s OFFSET %s", w.arg(limit+1), w.arg(offset))
+
+	rows, err := s.pool.Query(ctx, sql, w.args...)
+	if err != nil {
+		return ingest.PostPage{}, err
+	}
+	items, err := collectPosts(rows)
+	if err != nil {
+		return ingest.PostPage{}, err
+	}
+
+	page := ingest.PostPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		next := ingest.NewCursor(q.Sort, page.Items[limit-1])
+		page.Next = &next
+	}
+	return page, nil
+}
+
+// ExportPosts hands every post matching q, in q's order, to fn. Rows are
+// decoded one at a time as pgx reads them off the connection, so memory use
+// does not grow with the result. A positive q.Limit caps the number of posts;
+// unlike QueryPosts there is no maximum. An error from fn stops the export
+// and is returned.
+func (s *PGStore) ExportPosts(ctx context.Context, q ingest.PostQuery, fn func(models.EnrichedPost) error) error {
+	if q.Sort.Field == "" {
+		q.Sort = ingest.DefaultSort
+	}
+	w, sql, err := selectPosts(q)
+	if err != nil {
+		return err
+	}
+	if q.Limit > 0 {
+		sql += "\nLIMIT " + w.arg(q.Limit)
+	}
+	if q.Offset > 0 && q.After == nil {
+		sql += "\nOFFSET " + w.arg(q.Offset)
+	}
+
+	rows, err := s.pool.Query(ctx, sql, w.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e, ok, err := scanPost(rows)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// selectPosts builds the query for the posts matching q in q's order,
+// resuming after q.After if set. Paging clauses are left to the caller,
+// binding their arguments through w.
+func selectPosts(q ingest.PostQuery) (*where, string, error) {
+	col, ok := sortColumns[q.Sort.Field]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: cannot sort by %q", ingest.ErrInvalidQuery, q.Sort.Field)
+	}
+
+	w := &where{}
+	w.filter(q)
+
+	// order by the sort column, then the rest of the key
+	keys := []string{col}
+	for _, k := range []string{"id", "user_id"} {
+		if k != col {
+			keys = append(keys, k)
+		}
+	}
+	dir, cmp := "ASC", ">"
+	if q.Sort.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if c := q.After; c != nil {
+		vals := make([]string, len(keys))
+		for i, k := range keys {
+			vals[i] = w.arg(cursorValue(k, c))
+		}
+		w.add(fmt.Sprintf("(%s) %s (%s)", strings.Join(keys, ", "), cmp, strings.Join(vals, ", ")))
+	}
+	order := make([]string, len(keys))
+	for i, k := range keys {
+		order[i] = k + " " + dir
+	}
+
+	sql := fmt.Sprintf(`
+SELECT %s, deleted_at, ingested_at
+FROM posts
+%s
+ORDER BY %s`, docColumn(q.QueryOptions), w.clause(), strings.Join(order, ", "))
+	return w, sql, nil
+}
+
+// docColumn selects the stored document, without the raw upstream object
+// unless it was asked for.
+func docColumn(opts ingest.QueryOptions) string {
+	if opts.IncludeRaw {
+		return "doc"
+	}
+	return "doc - 'raw' AS doc"
+}
+
+// cursorValue returns the cursor's value for key column k.
+func cursorValue(k string, c *ingest.Cursor) any {
+	switch k {
+	case "ingested_at":
+		return c.IngestedAt
+	case "id":
+		return c.ID
+	case "user_id":
+		return c.UserID
+	default: // title, source
+		return c.Text
+	}
+}
+
+// where accumulates AND-ed conditions and their positional arguments.
+type where struct {
+	conds []string
+	args  []any
+}
+
+// arg binds v and returns its placeholder.
+func (w *where) arg(v any) string {
+	w.args = append(w.args, v)
+	return "$" + strconv.Itoa(len(w.args))
+}
+
+func (w *where) add(cond string) {
+	w.conds = append(w.conds, cond)
+}
+
+// filter adds the conditions of q's filters.
+func (w *where) filter(q ingest.PostQuery) {
+	if !q.IncludeDeleted {
+		w.add("deleted_at IS NULL")
+	}
+	if len(q.Sources) > 0 {
+		w.add("source = ANY(" + w.arg(q.Sources) + ")")
+	}
+	if len(q.UserIDs) > 0 {
+		w.add("user_id = ANY(" + w.arg(q.UserIDs) + ")")
+	}
+	if len(q.IDRanges) > 0 {
+		ranges := make([]string, len(q.IDRanges))
+		for i, r := range q.IDRanges {
+			ranges[i] = "id BETWEEN " + w.arg(r.Min) + " AND " + w.arg(r.Max)
+		}
+		w.add("(" + strings.Join(ranges, " OR ") + ")")
+	}
+	if !q.IngestedSince.IsZero() {
+		w.add("ingested_at >= " + w.arg(q.IngestedSince))
+	}
+	if !q.IngestedUntil.IsZero() {
+		w.add("ingested_at < " + w.arg(q.IngestedUntil))
+	}
+	if q.TitleContains != "" {
+		w.add("title ILIKE " + w.arg("%"+escapeLike(q.TitleContains)+"%"))
+	}
+	if len(q.DocContains) > 0 {
+		// served by idx_posts_doc_gin
+		w.add("doc @> " + w.arg(string(q.DocContains)) + "::jsonb")
+	}
+}
+
+func (w *where) clause() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(w.conds, "\n  AND ")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
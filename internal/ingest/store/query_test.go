@@ -0,0 +1,45 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+)
+
+func TestWhere_Filter(t *testing.T) {
+	since := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	var w where
+	w.filter(ingest.PostQuery{
+		Sources:       []string{"a", "b"},
+		UserIDs:       []int{1, 2},
+		IDRanges:      []ingest.IDRange{{Min: 1, Max: 10}, {Min: 20, Max: 20}},
+		IngestedSince: since,
+		TitleContains: "50%_off",
+		DocContains:   []byte(`{"raw":{"lang":"en"}}`),
+	})
+
+	wantSQL := "WHERE deleted_at IS NULL\n" +
+		"  AND source = ANY($1)\n" +
+		"  AND user_id = ANY($2)\n" +
+		"  AND (id BETWEEN $3 AND $4 OR id BETWEEN $5 AND $6)\n" +
+		"  AND ingested_at >= $7\n" +
+		"  AND title ILIKE $8\n" +
+		"  AND doc @> $9::jsonb"
+	if got := w.clause(); got != wantSQL {
+		t.Fatalf("clause:\n%s\nwant:\n%s", got, wantSQL)
+	}
+	wantArgs := []any{[]string{"a", "b"}, []int{1, 2}, 1, 10, 20, 20, since, `%50\%\_off%`, `{"raw":{"lang":"en"}}`}
+	if !reflect.DeepEqual(w.args, wantArgs) {
+		t.Fatalf("args: got %#v, want %#v", w.args, wantArgs)
+	}
+}
+
+func TestWhere_NoFilters(t *testing.T) {
+	var w where
+	w.filter(ingest.PostQuery{QueryOptions: ingest.QueryOptions{IncludeDeleted: true}})
+	if got := w.clause(); got != "" || len(w.args) != 0 {
+		t.Fatalf("expected no conditions, got %q %v", got, w.args)
+	}
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+const runColumns = `id, source, status, started_at, finished_at, fetched, inserted, updated, unchanged, rejected, deleted, error`
+
+// CreateRun records the start of an ingestion run and returns its ID.
+func (s *PGStore) CreateRun(ctx context.Context, run models.Run) (int64, error) {
+	var id int64
+	err := s.pool.QueryRow(ctx, `
+INSERT INTO ingest_runs (source, status, started_at)
+VALUES ($1,$2,$3)
+RETURNING id`, run.Source, run.Status, run.StartedAt).Scan(&id)
+	return id, err
+}
+
+// FinishRun stores the outcome of a run created by CreateRun.
+func (s *PGStore) FinishRun(ctx context.Context, run models.Run) error {
+	tag, err := s.pool.Exec(ctx, `
+UPDATE ingest_runs SET
+  status=$2, finished_at=$3, fetched=$4, inserted=$5, updated=$6, unchanged=$7,
+  rejected=$8, deleted=$9, error=$10
+WHERE id=$1`,
+		run.ID, run.Status, run.FinishedAt, run.Fetched, run.Inserted, run.Updated, run.Unchanged,
+		run.Rejected, run.Deleted, run.Error)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ingest.ErrNotFound
+	}
+	return nil
+}
+
+// FailStaleRuns marks the runs of source still recorded as running that
+// started before startedBefore as failed with reason.
+func (s *PGStore) FailStaleRuns(ctx context.Context, source string, startedBefore time.Time, reason string) (int, error) {
+	tag, err := s.pool.Exec(ctx, `
+UPDATE ingest_runs SET status=$4, finished_at=now(), error=$5
+WHERE source=$1 AND status=$2 AND started_at < $3`,
+		source, models.RunRunning, startedBefore, models.RunFailed, reason)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (s *PGStore) ListRuns(ctx context.Context, q ingest.RunQuery) ([]models.Run, error) {
+	// sane limits
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	if q.Limit > 500 {
+		q.Limit = 500
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	sql, args := listRunsQuery(q)
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.Run{}
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// listRunsQuery builds the ListRuns statement. The source condition is only
+// added when filtering, so that idx_ingest_runs_source_started serves it.
+func listRunsQuery(q ingest.RunQuery) (string, []any) {
+	var w where
+	if q.Source != "" {
+		w.add("source = " + w.arg(q.Source))
+	}
+	sql := `
+SELECT ` + runColumns + `
+FROM ingest_runs
+` + w.clause() + `
+ORDER BY started_at DESC, id DESC
+LIMIT ` + w.arg(q.Limit) + ` OFFSET ` + w.arg(q.Offset)
+	return sql, w.args
+}
+
+func (s *PGStore) GetRun(ctx context.Context, id int64) (models.Run, error) {
+	r, err := scanRun(s.pool.QueryRow(ctx, `SELECT `+runColumns+` FROM ingest_runs WHERE id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Run{}, ingest.ErrNotFound
+	}
+	return r, err
+}
+
+func scanRun(row pgx.Row) (models.Run, error) {
+	var r models.Run
+	err := row.Scan(&r.ID, &r.Source, &r.Status, &r.StartedAt, &r.FinishedAt,
+		&r.Fetched, &r.Inserted, &r.Updated, &r.Unchanged, &r.Rejected, &r.Deleted, &r.Error)
+	return r, err
+}
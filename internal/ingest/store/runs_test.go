@@ -0,0 +1,27 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+)
+
+func TestListRunsQuery(t *testing.T) {
+	sql, args := listRunsQuery(ingest.RunQuery{Source: "a", Limit: 10, Offset: 20})
+	if !strings.Contains(sql, "WHERE source = $1\n") || !strings.Contains(sql, "LIMIT $2 OFFSET $3") {
+		t.Fatalf("filtered:\n%s", sql)
+	}
+	if want := []any{"a", 10, 20}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("filtered args: got %#v, want %#v", args, want)
+	}
+
+	sql, args = listRunsQuery(ingest.RunQuery{Limit: 10})
+	if strings.Contains(sql, "WHERE") || !strings.Contains(sql, "LIMIT $1 OFFSET $2") {
+		t.Fatalf("unfiltered:\n%s", sql)
+	}
+	if want := []any{10, 0}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("unfiltered args: got %#v, want %#v", args, want)
+	}
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// headline options for the title and body snippets of search hits
+const (
+	titleHeadline = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+	bodyHeadline  = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=5, MaxWords=20"
+)
+
+// searchLanguage returns the text search configuration of source's posts.
+func (s *PGStore) searchLanguage(source string) string {
+	if lang := s.SearchLanguages[source]; lang != "" {
+		return lang
+	}
+	if s.DefaultSearchLanguage != "" {
+		return s.DefaultSearchLanguage
+	}
+	return "english"
+}
+
+// searchLanguages returns every configuration posts may be indexed with.
+func (s *PGStore) searchLanguages() []string {
+	set := map[string]bool{s.searchLanguage(""): true}
+	for _, lang := range s.SearchLanguages {
+		if lang != "" {
+			set[lang] = true
+		}
+	}
+	out := make([]string, 0, len(set))
+	for lang := range set {
+		out = append(out, lang)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// CheckSearchLanguages verifies that every configured text search
+// configuration exists, so a typo fails at startup instead of every upsert.
+func (s *PGStore) CheckSearchLanguages(ctx context.Context) error {
+	for _, lang := range s.searchLanguages() {
+		if _, err := s.pool.Exec(ctx, `SELECT $1::text::regconfig`, lang); err != nil {
+			return fmt.Errorf("search language %q: %w", lang, err)
+		}
+	}
+	return nil
+}
+
+// SearchPosts matches q.Text against the search_vector of the posts passing
+// q's filters, best rank first. Each post is searched with the configuration
+// it was indexed with: the query is parsed once per configured language and
+// joined to the posts of that language, which keeps idx_posts_search usable.
+func (s *PGStore) SearchPosts(ctx context.Context, q ingest.SearchQuery) ([]models.SearchHit, error) {
+	tsq, err := toTSQuery(q.Text)
+	if err != nil {
+		return nil, err
+	}
+	limit, offset := q.Limit, q.Offset
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	var w where
+	text, langs := w.arg(tsq), w.arg(s.searchLanguages())
+	w.add("search_vector @@ q.query")
+	w.filter(q.PostQuery)
+
+	sql := fmt.Sprintf(`
+WITH q AS (
+  SELECT lang::regconfig AS lang, to_tsquery(lang::regconfig, %s) AS query
+  FROM unnest(%s::text[]) AS lang
+), hits AS (
+  SELECT %s, deleted_at, ingested_at, id, user_id, title, body, search_lang, q.query,
+         ts_rank_cd(search_vector, q.query) AS rank
+  FROM posts JOIN q ON posts.search_lang = q.lang
+  %s
+  ORDER BY rank DESC, ingested_at DESC, id DESC, user_id DESC
+  LIMIT %s OFFSET %s
+)
+SELECT doc, deleted_at, ingested_at, rank,
+       ts_headline(search_lang, title, query, %s),
+       ts_headline(search_lang, body, query, %s)
+FROM hits
+ORDER BY rank DESC, ingested_at DESC, id DESC, user_id DESC`,
+		text, langs, docColumn(q.QueryOptions), w.clause(), w.arg(limit), w.arg(offset), w.arg(titleHeadline), w.arg(bodyHeadline))
+
+	rows, err := s.pool.Query(ctx, sql, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.SearchHit
+	for rows.Next() {
+		var (
+			h          models.SearchHit
+			raw        []byte
+			deletedAt  *time.Time
+			ingestedAt time.Time
+		)
+		if err := rows.Scan(&raw, &deletedAt, &ingestedAt, &h.Rank, &h.Highlights.Title, &h.Highlights.Body); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &h.EnrichedPost); err != nil {
+			continue
+		}
+		h.DeletedAt = deletedAt
+		h.IngestedAt = ingestedAt.UTC()
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// toTSQuery translates the search syntax of ingest.SearchQuery into
+// to_tsquery input. Words are reduced to letters and digits, so the result
+// holds no operator the user did not ask for: terms are AND-ed, "a b"
+// becomes a <-> b, a* becomes a:*, -a becomes !a and OR becomes |.
+func toTSQuery(text string) (string, error) {
+	var (
+		b      strings.Builder
+		pendOr bool
+	)
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		neg := false
+		if rest[0] == '-' {
+			neg, rest = true, rest[1:]
+		}
+
+		var term string
+		phrase := rest != "" && rest[0] == '"'
+		if phrase {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				term, rest = rest[1:], ""
+			} else {
+				term, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			term, rest = rest[:end], rest[end:]
+		}
+		rest = strings.TrimSpace(rest)
+
+		if !phrase && !neg && term == "OR" {
+			pendOr = b.Len() > 0
+			continue
+		}
+		expr := lexemes(term)
+		if expr == "" {
+			continue
+		}
+		if neg {
+			expr = "!" + expr
+		}
+		if b.Len() > 0 {
+			if pendOr {
+				b.WriteString(" | ")
+			} else {
+				b.WriteString(" & ")
+			}
+		}
+		b.WriteString(expr)
+		pendOr = false
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("%w: no searchable words in %q", ingest.ErrInvalidQuery, text)
+	}
+	return b.String(), nil
+}
+
+// lexemes turns a term or phrase into a to_tsquery operand: one word, or
+// several joined with the followed-by operator. A trailing * on a word makes
+// it a prefix match.
+func lexemes(term string) string {
+	fields := strings.FieldsFunc(term, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '*'
+	})
+	var words []string
+	for _, f := range fields {
+		w := strings.ReplaceAll(f, "*", "")
+		if w == "" {
+			continue
+		}
+		if strings.HasSuffix(f, "*") {
+			w += ":*"
+		}
+		words = append(words, w)
+	}
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		return words[0]
+	}
+	return "(" + strings.Join(words, " <-> ") + ")"
+}
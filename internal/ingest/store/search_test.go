@@ -0,0 +1,50 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+)
+
+func TestToTSQuery(t *testing.T) {
+	for in, want := range map[string]string{
+		"hello world":                "hello & world",
+		`"quick brown" fox*`:         "(quick <-> brown) & fox:*",
+		"cats OR dogs -birds":        "cats | dogs & !birds",
+		`-"spam mail" e-mail`:        "!(spam <-> mail) & (e <-> mail)",
+		`"unterminated phrase`:       "(unterminated <-> phrase)",
+		"a:*|b&!c":                   "(a <-> b <-> c)",
+		"OR leading and trailing OR": "leading & and & trailing",
+		"Grüße 2025":                 "Grüße & 2025",
+	} {
+		got, err := toTSQuery(in)
+		if err != nil || got != want {
+			t.Errorf("toTSQuery(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "   ", "-", "OR", `""`, "*", "&|!"} {
+		if _, err := toTSQuery(in); !errors.Is(err, ingest.ErrInvalidQuery) {
+			t.Errorf("toTSQuery(%q): want ErrInvalidQuery, got %v", in, err)
+		}
+	}
+}
+
+func TestSearchLanguages(t *testing.T) {
+	s := &PGStore{SearchLanguages: map[string]string{"a": "german", "b": "english", "c": ""}}
+	if got := s.searchLanguage("a"); got != "german" {
+		t.Errorf("searchLanguage(a) = %q", got)
+	}
+	if got := s.searchLanguage("c"); got != "english" {
+		t.Errorf("searchLanguage(c) = %q, want the default", got)
+	}
+	if got, want := s.searchLanguages(), []string{"english", "german"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("searchLanguages() = %v, want %v", got, want)
+	}
+
+	s.DefaultSearchLanguage = "simple"
+	if got, want := s.searchLanguages(), []string{"english", "german", "simple"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("searchLanguages() = %v, want %v", got, want)
+	}
+}
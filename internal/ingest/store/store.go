@@ -3,6 +3,8 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -10,114 +12,130 @@ import (
 	"github.com/renix-codex/ingestor/internal/models" // import ONLY for the types
 )
 
-type PGStore struct{ pool *pgxpool.Pool }
+type PGStore struct {
+	pool *pgxpool.Pool
 
-// Ensure PGStore implements the ingest.StorePort interface.
-var _ ingest.StorePort = (*PGStore)(nil)
+	// UpsertMode says what Upsert does when the database refuses a post;
+	// empty means UpsertAtomic.
+	UpsertMode UpsertMode
 
+	// Upsert calls with at least BulkThreshold posts are loaded with COPY
+	// (see bulkUpsert) in chunks of at most BulkChunkSize posts.
+	// Zero values mean DefaultBulkThreshold and DefaultBulkChunkSize; a
+	// negative BulkThreshold disables the bulk path.
+	BulkThreshold int
+	BulkChunkSize int
+
+	// SearchLanguages maps a source to the text search configuration its
+	// posts are indexed with; other sources use DefaultSearchLanguage, or
+	// "english" if that is empty.
+	SearchLanguages       map[string]string
+	DefaultSearchLanguage string
+}
+
+const (
+	// DefaultBulkThreshold is the streaming batch size, so that full batches
+	// of a streamed run are loaded with COPY.
+	DefaultBulkThreshold = ingest.DefaultBatchSize
+	DefaultBulkChunkSize = 10000
+)
+
+// Ensure PGStore implements the ingest.StorePort and ingest.ValidatorStore interfaces.
+var (
+	_ ingest.StorePort      = (*PGStore)(nil)
+	_ ingest.ValidatorStore = (*PGStore)(nil)
+)
+
+// New connects to Postgres and applies any pending schema migrations.
 func New(ctx context.Context, dsn string) (*PGStore, error) {
-	pool, err := pgxpool.New(ctx, dsn)
+	s, err := Connect(ctx, dsn)
 	if err != nil {
 		return nil, err
 	}
-	_, err = pool.Exec(ctx, `
-CREATE TABLE IF NOT EXISTS posts (
-  user_id INT NOT NULL,
-  id INT NOT NULL,
-  title TEXT NOT NULL,
-  body TEXT NOT NULL,
-  ingested_at TIMESTAMPTZ NOT NULL,
-  source TEXT NOT NULL,
-  doc JSONB NOT NULL,
-  PRIMARY KEY (user_id, id)
-);
-CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts(user_id);
-CREATE INDEX IF NOT EXISTS idx_posts_doc_gin ON posts USING GIN (doc);
-`)
+	m, err := s.Migrator()
 	if err != nil {
 		return nil, err
 	}
-	return &PGStore{pool: pool}, nil
-}
-
-// --- your exact methods, unchanged ---
-
-func (s *PGStore) Upsert(ctx context.Context, items []models.EnrichedPost) error {
-	b := &pgx.Batch{}
-	for _, it := range items {
-		raw, _ := json.Marshal(it)
-		b.Queue(`
-INSERT INTO posts (user_id,id,title,body,ingested_at,source,doc)
-VALUES ($1,$2,$3,$4,$5,$6,$7)
-ON CONFLICT (user_id,id) DO UPDATE SET
-  title=EXCLUDED.title, body=EXCLUDED.body,
-  ingested_at=EXCLUDED.ingested_at, source=EXCLUDED.source, doc=EXCLUDED.doc`,
-			it.UserID, it.ID, it.Title, it.Body, it.IngestedAt, it.Source, raw)
-	}
-	br := s.pool.SendBatch(ctx, b)
-	defer br.Close()
-	for range items {
-		if _, err := br.Exec(); err != nil {
-			return err
-		}
+	if _, err := m.Up(ctx); err != nil {
+		s.Close()
+		return nil, err
 	}
-	return nil
+	return s, nil
 }
 
-func (s *PGStore) QueryByUser(ctx context.Context, userID int) ([]models.EnrichedPost, error) {
-	rows, err := s.pool.Query(ctx, `SELECT doc FROM posts WHERE user_id=$1 ORDER BY id`, userID)
+// Connect connects to Postgres without touching the schema.
+func Connect(ctx context.Context, dsn string) (*PGStore, error) {
+	pool, err := pgxpool.New(ctx, dsn)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &PGStore{pool: pool}, nil
+}
 
+// Migrator returns a migrator for the store's database.
+func (s *PGStore) Migrator() (*Migrator, error) {
+	return NewMigrator(s.pool)
+}
+
+// Close closes the connection pool.
+func (s *PGStore) Close() {
+	s.pool.Close()
+}
+
+// collectPosts reads (doc, deleted_at, ingested_at) rows.
+func collectPosts(rows pgx.Rows) ([]models.EnrichedPost, error) {
+	defer rows.Close()
 	var out []models.EnrichedPost
 	for rows.Next() {
-		var raw []byte
-		if err := rows.Scan(&raw); err != nil {
+		e, ok, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-		var e models.EnrichedPost
-		if err := json.Unmarshal(raw, &e); err == nil {
+		if ok {
 			out = append(out, e)
 		}
 	}
 	return out, rows.Err()
 }
 
-func (s *PGStore) QueryRecent(ctx context.Context, limit, offset int) ([]models.EnrichedPost, error) {
-	// sane limits
-	if limit <= 0 {
-		limit = 50
-	}
-	if limit > 500 {
-		limit = 500
+// scanPost reads the current (doc, deleted_at, ingested_at) row; ok is false
+// for a doc that does not decode. deleted_at is kept outside the doc because
+// tombstoning does not rewrite it, and ingested_at is taken from the column
+// so cursors match the stored (microsecond) precision.
+func scanPost(rows pgx.Rows) (e models.EnrichedPost, ok bool, err error) {
+	var raw []byte
+	var deletedAt *time.Time
+	var ingestedAt time.Time
+	if err := rows.Scan(&raw, &deletedAt, &ingestedAt); err != nil {
+		return e, false, err
 	}
-	if offset < 0 {
-		offset = 0
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return e, false, nil
 	}
+	e.DeletedAt = deletedAt
+	e.IngestedAt = ingestedAt.UTC()
+	return e, true, nil
+}
 
-	rows, err := s.pool.Query(ctx, `
-SELECT doc
-FROM posts
-ORDER BY ingested_at DESC, id DESC
-LIMIT $1 OFFSET $2
-`, limit, offset)
-	if err != nil {
-		return nil, err
+// LoadValidators returns the cache validators stored for source, or zero
+// validators if there are none.
+func (s *PGStore) LoadValidators(ctx context.Context, source string) (ingest.Validators, error) {
+	var v ingest.Validators
+	err := s.pool.QueryRow(ctx,
+		`SELECT etag, last_modified FROM source_validators WHERE source=$1`, source,
+	).Scan(&v.ETag, &v.LastModified)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ingest.Validators{}, nil
 	}
-	defer rows.Close()
+	return v, err
+}
 
-	var out []models.EnrichedPost
-	for rows.Next() {
-		var raw []byte
-		if err := rows.Scan(&raw); err != nil {
-			return nil, err
-		}
-		var e models.EnrichedPost
-		if err := json.Unmarshal(raw, &e); err == nil {
-			out = append(out, e)
-		}
-	}
-	return out, rows.Err()
+func (s *PGStore) SaveValidators(ctx context.Context, source string, v ingest.Validators) error {
+	_, err := s.pool.Exec(ctx, `
+INSERT INTO source_validators (source, etag, last_modified, updated_at)
+VALUES ($1,$2,$3,now())
+ON CONFLICT (source) DO UPDATE SET
+  etag=EXCLUDED.etag, last_modified=EXCLUDED.last_modified, updated_at=EXCLUDED.updated_at`,
+		source, v.ETag, v.LastModified)
+	return err
 }
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/renix-codex/ingestor/internal/ingest"
+)
+
+// Tombstone sets deleted_at on the live posts of req.Source that are not in
+// req.Seen and closes their current revision. It runs in one transaction and
+// deletes nothing if the share of live posts to delete exceeds req.MaxFraction.
+func (s *PGStore) Tombstone(ctx context.Context, req ingest.TombstoneRequest) (int, error) {
+	userIDs := make([]int32, len(req.Seen))
+	ids := make([]int32, len(req.Seen))
+	for i, k := range req.Seen {
+		userIDs[i], ids[i] = int32(k.UserID), int32(k.ID)
+	}
+
+	var deleted int
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+CREATE TEMP TABLE seen_posts (user_id INT, id INT, PRIMARY KEY (user_id, id)) ON COMMIT DROP`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+INSERT INTO seen_posts SELECT * FROM unnest($1::int[], $2::int[]) ON CONFLICT DO NOTHING`,
+			userIDs, ids); err != nil {
+			return err
+		}
+
+		var missing, live int
+		if err := tx.QueryRow(ctx, `
+SELECT count(*) FILTER (WHERE s.user_id IS NULL), count(*)
+FROM posts p LEFT JOIN seen_posts s USING (user_id, id)
+WHERE p.source = $1 AND p.deleted_at IS NULL`, req.Source).Scan(&missing, &live); err != nil {
+			return err
+		}
+		if missing == 0 {
+			return nil
+		}
+		if float64(missing) > req.MaxFraction*float64(live) {
+			return fmt.Errorf("%w: %d of %d live posts of %s (max %.0f%%)",
+				ingest.ErrDeleteThreshold, missing, live, req.Source, req.MaxFraction*100)
+		}
+
+		return tx.QueryRow(ctx, `
+WITH gone AS (
+  UPDATE posts p SET deleted_at = $2
+  WHERE p.source = $1 AND p.deleted_at IS NULL
+    AND NOT EXISTS (SELECT 1 FROM seen_posts s WHERE s.user_id = p.user_id AND s.id = p.id)
+  RETURNING p.user_id, p.id
+), closed AS (
+  UPDATE post_versions v SET valid_to = $2
+  FROM gone
+  WHERE v.user_id = gone.user_id AND v.id = gone.id AND v.valid_to IS NULL
+)
+SELECT count(*) FROM gone`, req.Source, req.At).Scan(&deleted)
+	})
+	return deleted, err
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// UpsertMode selects how Upsert treats posts the database refuses.
+type UpsertMode string
+
+const (
+	// UpsertAtomic writes all posts of an Upsert call or none of them.
+	UpsertAtomic UpsertMode = "atomic"
+	// UpsertPerRow isolates every post in a savepoint, commits the good ones
+	// and reports the refused ones in UpsertResult.Rejected.
+	UpsertPerRow UpsertMode = "per_row"
+)
+
+// upsertConflict decides which existing rows an insert into posts rewrites:
+// changed, moved or tombstoned posts, and posts whose source now has another
+// search language, so that they are re-indexed.
+const upsertConflict = `
+  ON CONFLICT (user_id,id) DO UPDATE SET
+    title=EXCLUDED.title, body=EXCLUDED.body,
+    ingested_at=EXCLUDED.ingested_at, source=EXCLUDED.source,
+    content_hash=EXCLUDED.content_hash, doc=EXCLUDED.doc, search_lang=EXCLUDED.search_lang,
+    deleted_at=NULL
+  WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+     OR posts.source <> EXCLUDED.source
+     OR posts.deleted_at IS NOT NULL
+     OR posts.search_lang IS DISTINCT FROM EXCLUDED.search_lang
+  RETURNING user_id, id, title, body, source, content_hash, ingested_at, (xmax = 0) AS inserted`
+
+// recordVersions follows an "up" CTE of written posts: it closes their
+// current revision and opens a new one. A rewrite that leaves title, body
+// and source as they were, such as a change to an unmapped upstream field in
+// the raw object, keeps the current revision. Both statements see
+// post_versions as it was before either ran.
+const recordVersions = `
+changed AS (
+  SELECT up.* FROM up
+  WHERE NOT EXISTS (
+    SELECT 1 FROM post_versions v
+    WHERE v.user_id = up.user_id AND v.id = up.id AND v.valid_to IS NULL
+      AND v.title = up.title AND v.body = up.body AND v.source = up.source)
+), closed AS (
+  UPDATE post_versions v SET valid_to = changed.ingested_at
+  FROM changed
+  WHERE v.user_id = changed.user_id AND v.id = changed.id AND v.valid_to IS NULL
+), opened AS (
+  INSERT INTO post_versions (user_id, id, title, body, source, content_hash, valid_from)
+  SELECT user_id, id, title, body, source, content_hash, ingested_at FROM changed
+)`
+
+// upsertPost writes one post and, if it was inserted or changed, records
+// the new revision in post_versions, closing the previous one. It returns
+// whether the row was inserted, or no row if the post was unchanged.
+const upsertPost = `
+WITH up AS (
+  INSERT INTO posts (user_id,id,title,body,ingested_at,source,content_hash,doc,search_lang)
+  VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9::text::regconfig)` + upsertConflict + `
+),` + recordVersions + `
+SELECT inserted FROM up`
+
+// Upsert writes items keyed by (user_id, id) in a transaction. Existing rows
+// are only rewritten when their content hash, source or search language
+// changed or they had been tombstoned (which revives them); the result counts inserted, updated
+// and unchanged rows. Of several items with the same key only the last is
+// written, the others are counted as duplicates. Every insert or change of
+// title, body or source is kept as a revision in post_versions. What happens
+// to posts the database refuses depends on UpsertMode.
+func (s *PGStore) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	items, dups := dedupe(items)
+	res, err := s.upsert(ctx, items)
+	res.Duplicates = dups
+	return res, err
+}
+
+// dedupe drops every item followed by another item with the same key, so
+// that the row-wise and the bulk path see the same distinct posts, and
+// returns how many it dropped. The order of the remaining items is kept.
+func dedupe(items []models.EnrichedPost) ([]models.EnrichedPost, int) {
+	last := make(map[models.PostKey]int, len(items))
+	for i, it := range items {
+		last[models.PostKey{UserID: it.UserID, ID: it.ID}] = i
+	}
+	if len(last) == len(items) {
+		return items, 0
+	}
+	out := make([]models.EnrichedPost, 0, len(last))
+	for i, it := range items {
+		if last[models.PostKey{UserID: it.UserID, ID: it.ID}] == i {
+			out = append(out, it)
+		}
+	}
+	return out, len(items) - len(out)
+}
+
+func (s *PGStore) upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	if s.useBulk(len(items)) {
+		return s.bulkUpsert(ctx, items)
+	}
+
+	var res models.UpsertResult
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var err error
+		if s.UpsertMode == UpsertPerRow {
+			// optimistically send the whole batch, isolating rows only if it fails
+			res, err = inSavepoint(ctx, tx, func(sp pgx.Tx) (models.UpsertResult, error) {
+				return s.sendUpserts(ctx, sp, items)
+			})
+			if isRowError(err) {
+				res, err = s.upsertEachRow(ctx, tx, items)
+			}
+			return err
+		}
+		res, err = s.sendUpserts(ctx, tx, items)
+		return err
+	})
+	if err != nil {
+		// the transaction was rolled back
+		return models.UpsertResult{}, err
+	}
+	return res, nil
+}
+
+// upsertEachRow writes items one by one, each in its own savepoint, and
+// collects the rows the database refused instead of failing.
+func (s *PGStore) upsertEachRow(ctx context.Context, tx pgx.Tx, items []models.EnrichedPost) (models.UpsertResult, error) {
+	var res models.UpsertResult
+	for i, it := range items {
+		r, err := inSavepoint(ctx, tx, func(sp pgx.Tx) (models.UpsertResult, error) {
+			return s.sendUpserts(ctx, sp, items[i:i+1])
+		})
+		if isRowError(err) {
+			res.Rejected = append(res.Rejected, models.RejectedPost{UserID: it.UserID, ID: it.ID, Error: err.Error()})
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Add(r)
+	}
+	return res, nil
+}
+
+// sendUpserts queues upsertPost for every item in one batch.
+func (s *PGStore) sendUpserts(ctx context.Context, tx pgx.Tx, items []models.EnrichedPost) (models.UpsertResult, error) {
+	var res models.UpsertResult
+	b := &pgx.Batch{}
+	for _, it := range items {
+		raw, _ := json.Marshal(it)
+		// xmax is 0 only for freshly inserted row versions; a skipped
+		// update returns no row at all
+		b.Queue(upsertPost,
+			it.UserID, it.ID, it.Title, it.Body, it.IngestedAt, it.Source, it.ContentHash, raw,
+			s.searchLanguage(it.Source))
+	}
+	br := tx.SendBatch(ctx, b)
+	defer br.Close()
+	for range items {
+		var inserted bool
+		err := br.QueryRow().Scan(&inserted)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			res.Unchanged++
+		case err != nil:
+			return res, err
+		case inserted:
+			res.Inserted++
+		default:
+			res.Updated++
+		}
+	}
+	return res, br.Close()
+}
+
+// inSavepoint runs fn in a savepoint of tx, rolling back to it if fn fails.
+func inSavepoint(ctx context.Context, tx pgx.Tx, fn func(sp pgx.Tx) (models.UpsertResult, error)) (models.UpsertResult, error) {
+	var res models.UpsertResult
+	err := pgx.BeginFunc(ctx, tx, func(sp pgx.Tx) error {
+		var err error
+		res, err = fn(sp)
+		return err
+	})
+	return res, err
+}
+
+// isRowError reports whether err is the database refusing the data itself
+// (a data exception or constraint violation), as opposed to a connection,
+// cancellation or server problem that would affect any row.
+func isRowError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || len(pgErr.Code) < 2 {
+		return false
+	}
+	class := pgErr.Code[:2]
+	return class == "22" || class == "23"
+}
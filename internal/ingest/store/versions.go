@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+const versionColumns = `user_id, id, title, body, source, content_hash, valid_from, valid_to`
+
+// ListVersions returns every recorded revision of a post, oldest first.
+func (s *PGStore) ListVersions(ctx context.Context, userID, id int) ([]models.PostVersion, error) {
+	rows, err := s.pool.Query(ctx, `
+SELECT `+versionColumns+`
+FROM post_versions
+WHERE user_id=$1 AND id=$2
+ORDER BY valid_from`, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return collectVersions(rows)
+}
+
+// QueryAsOf returns the revisions that were current at q.At.
+func (s *PGStore) QueryAsOf(ctx context.Context, q ingest.AsOfQuery) ([]models.PostVersion, error) {
+	// sane limits
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	if q.Limit > 500 {
+		q.Limit = 500
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	rows, err := s.pool.Query(ctx, `
+SELECT `+versionColumns+`
+FROM post_versions
+WHERE valid_from <= $1 AND (valid_to IS NULL OR valid_to > $1)
+  AND ($2 = 0 OR user_id = $2)
+ORDER BY user_id, id
+LIMIT $3 OFFSET $4
+`, q.At, q.UserID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return collectVersions(rows)
+}
+
+func collectVersions(rows pgx.Rows) ([]models.PostVersion, error) {
+	defer rows.Close()
+	out := []models.PostVersion{}
+	for rows.Next() {
+		var v models.PostVersion
+		if err := rows.Scan(&v.UserID, &v.ID, &v.Title, &v.Body, &v.Source, &v.ContentHash,
+			&v.ValidFrom, &v.ValidTo); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
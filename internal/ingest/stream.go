@@ -0,0 +1,173 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// DefaultBatchSize is used by streaming collectors when none is configured.
+const DefaultBatchSize = 500
+
+// ErrBodyTooLarge is returned when an upstream body exceeds the configured limit.
+var ErrBodyTooLarge = errors.New("upstream body exceeds size limit")
+
+// decodeArray decodes a top-level JSON array of posts element by element,
+// calling fn with batches of at most batchSize posts. A JSON null is treated
+// as an empty array. fn owns each batch it receives.
+func decodeArray(r io.Reader, batchSize int, fn func([]models.Post) error) error {
+	return decodeElements(json.NewDecoder(r), batchSize, func(dec *json.Decoder) (models.Post, error) {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return models.Post{}, err
+		}
+		return decodePost(raw)
+	}, fn)
+}
+
+// decodePost decodes a post, keeping the upstream object as its Raw.
+func decodePost(raw json.RawMessage) (models.Post, error) {
+	var p models.Post
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return p, err
+	}
+	var err error
+	p.Raw, err = canonicalJSON(raw)
+	return p, err
+}
+
+// canonicalJSON re-encodes a JSON value with object keys sorted and no
+// insignificant whitespace, so an upstream that reorders keys or reformats
+// its output does not change the content hash. Numbers keep their literal
+// form.
+func canonicalJSON(raw []byte) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return marshalCanonical(v)
+}
+
+// marshalCanonical encodes a value decoded with UseNumber; encoding/json
+// writes map keys in sorted order.
+func marshalCanonical(v any) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// decodeElements decodes the JSON array at dec's position with decode, one
+// element at a time, calling fn with batches of at most batchSize posts.
+func decodeElements(dec *json.Decoder, batchSize int, decode func(*json.Decoder) (models.Post, error), fn func([]models.Post) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return decodeError(err)
+	}
+	if tok == nil {
+		return nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("%w: expected JSON array, got %v", ErrDecode, tok)
+	}
+
+	batch := make([]models.Post, 0, batchSize)
+	for dec.More() {
+		p, err := decode(dec)
+		if err != nil {
+			return decodeError(err)
+		}
+		batch = append(batch, p)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]models.Post, 0, batchSize)
+		}
+	}
+	if _, err := dec.Token(); err != nil { // closing ']'
+		return decodeError(err)
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// decodeNDJSON decodes newline-delimited JSON posts, calling fn with batches
+// of at most batchSize posts. Blank lines are skipped.
+func decodeNDJSON(r io.Reader, batchSize int, fn func([]models.Post) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	dec := json.NewDecoder(r)
+	batch := make([]models.Post, 0, batchSize)
+	for n := 1; ; n++ {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		var p models.Post
+		if err == nil {
+			p, err = decodePost(raw)
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, decodeError(err))
+		}
+		batch = append(batch, p)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]models.Post, 0, batchSize)
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// limitBody wraps r so that reading more than max bytes fails with
+// ErrBodyTooLarge. A non-positive max disables the limit.
+func limitBody(r io.Reader, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, remaining: max}
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	// read one byte past the limit so an exactly-sized body is accepted
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
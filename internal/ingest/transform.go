@@ -1,6 +1,9 @@
 package ingest
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"time"
 
 	"github.com/renix-codex/ingestor/internal/models"
@@ -10,13 +13,40 @@ func Enrich(posts []models.Post, source string, now func() time.Time) []models.E
 	out := make([]models.EnrichedPost, 0, len(posts))
 	for _, p := range posts {
 		out = append(out, models.EnrichedPost{
-			UserID:     p.UserID,
-			ID:         p.ID,
-			Title:      p.Title,
-			Body:       p.Body,
-			IngestedAt: now().UTC(),
-			Source:     source,
+			UserID:      p.UserID,
+			ID:          p.ID,
+			Title:       p.Title,
+			Body:        p.Body,
+			IngestedAt:  now().UTC(),
+			Source:      source,
+			ContentHash: ContentHash(p),
+			Raw:         p.Raw,
 		})
 	}
 	return out
 }
+
+// ContentHash returns a hex SHA-256 over the post's upstream fields, and the
+// raw upstream object if it was kept. It only changes when the content does,
+// so the store can skip rewriting identical rows. Fields are length-prefixed
+// so that e.g. ("ab","c") and ("a","bc") hash differently.
+func ContentHash(p models.Post) string {
+	h := sha256.New()
+	var buf [8]byte
+	writeInt := func(v int64) {
+		binary.BigEndian.PutUint64(buf[:], uint64(v))
+		h.Write(buf[:])
+	}
+	writeString := func(s string) {
+		writeInt(int64(len(s)))
+		h.Write([]byte(s))
+	}
+	writeInt(int64(p.UserID))
+	writeInt(int64(p.ID))
+	writeString(p.Title)
+	writeString(p.Body)
+	if len(p.Raw) > 0 {
+		writeString(string(p.Raw))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
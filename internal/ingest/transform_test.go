@@ -1,6 +1,7 @@
 package ingest
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -89,6 +90,54 @@ func TestEnrich_MultipleItems(t *testing.T) {
 	}
 }
 
+func TestContentHash(t *testing.T) {
+	p := models.Post{UserID: 1, ID: 2, Title: "ab", Body: "c"}
+	h := ContentHash(p)
+	if len(h) != 64 || h != ContentHash(p) {
+		t.Fatalf("expected a stable hex sha256, got %q", h)
+	}
+	for _, q := range []models.Post{
+		{UserID: 1, ID: 2, Title: "a", Body: "bc"},
+		{UserID: 1, ID: 2, Title: "ab", Body: "c "},
+		{UserID: 2, ID: 1, Title: "ab", Body: "c"},
+		{UserID: 1, ID: 2, Title: "ab", Body: "c", Raw: []byte(`{"likes":1}`)},
+	} {
+		if ContentHash(q) == h {
+			t.Errorf("expected %+v to hash differently from %+v", q, p)
+		}
+	}
+
+	// the hash covers content only, not when or from where it was ingested
+	a := Enrich([]models.Post{p}, "x", func() time.Time { return time.Unix(1, 0) })[0]
+	b := Enrich([]models.Post{p}, "y", func() time.Time { return time.Unix(2, 0) })[0]
+	if a.ContentHash != h || b.ContentHash != h {
+		t.Fatalf("expected Enrich to set the content hash, got %q and %q", a.ContentHash, b.ContentHash)
+	}
+}
+
+func TestContentHash_RawKeyOrder(t *testing.T) {
+	var hashes []string
+	for _, body := range []string{
+		`[{"userId":1,"id":2,"title":"t","meta":{"a":1,"b":[1.50,"<x>"]}}]`,
+		`[ {"meta": {"b": [1.50, "<x>"], "a": 1}, "title": "t", "id": 2, "userId": 1} ]`,
+	} {
+		var got []models.Post
+		if err := decodeArray(strings.NewReader(body), 0, func(batch []models.Post) error {
+			got = append(got, batch...)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, ContentHash(got[0]))
+		if want := `{"id":2,"meta":{"a":1,"b":[1.50,"<x>"]},"title":"t","userId":1}`; string(got[0].Raw) != want {
+			t.Fatalf("raw = %s, want %s", got[0].Raw, want)
+		}
+	}
+	if hashes[0] != hashes[1] {
+		t.Fatal("reordering keys must not change the content hash")
+	}
+}
+
 // Optional: lightweight benchmark to keep an eye on allocations/perf.
 func BenchmarkEnrich(b *testing.B) {
 	now := func() time.Time { return time.Unix(1_700_000_000, 0) }
@@ -1,19 +1,130 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Post struct {
 	UserID int    `json:"userId"`
 	ID     int    `json:"id"`
 	Title  string `json:"title"`
 	Body   string `json:"body"`
+
+	Raw json.RawMessage `json:"-"` // the upstream object the post was decoded from
 }
 
 type EnrichedPost struct {
-	UserID     int       `json:"userId"`
-	ID         int       `json:"id"`
-	Title      string    `json:"title"`
-	Body       string    `json:"body"`
-	IngestedAt time.Time `json:"ingested_at"`
-	Source     string    `json:"source"`
+	UserID      int        `json:"userId"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	IngestedAt  time.Time  `json:"ingested_at"`
+	Source      string     `json:"source"`
+	ContentHash string     `json:"content_hash"`         // hex SHA-256 of the upstream fields
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // set once the post disappeared upstream
+
+	// Raw is the upstream object with every field, mapped or not. It is
+	// stored in the doc column and only returned on request.
+	Raw json.RawMessage `json:"raw,omitempty"`
+}
+
+// SearchHit is a post matching a full-text search, with its relevance and
+// the matching parts of title and body highlighted.
+type SearchHit struct {
+	EnrichedPost
+	Rank       float64    `json:"rank"`
+	Highlights Highlights `json:"highlights"`
+}
+
+// Highlights are text fragments with matches wrapped in <mark>…</mark>; the
+// text itself is not HTML-escaped.
+type Highlights struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// PostKey identifies a post.
+type PostKey struct {
+	UserID int
+	ID     int
+}
+
+// PostVersion is one revision of a post, current from ValidFrom until
+// ValidTo (nil while it is still the current revision).
+type PostVersion struct {
+	UserID      int        `json:"userId"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Source      string     `json:"source"`
+	ContentHash string     `json:"content_hash"`
+	ValidFrom   time.Time  `json:"valid_from"`
+	ValidTo     *time.Time `json:"valid_to,omitempty"`
+}
+
+// SourceStatus summarises the ingestion state of one source.
+type SourceStatus struct {
+	Name           string     `json:"name"`
+	Running        bool       `json:"running"`
+	LastRunID      int64      `json:"last_run_id,omitempty"`
+	LastStartedAt  *time.Time `json:"last_started_at,omitempty"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	LastSuccessAt  *time.Time `json:"last_success_at,omitempty"`
+	LastCount      int        `json:"last_count"`
+	LastError      string     `json:"last_error,omitempty"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+}
+
+// UpsertResult counts how a write affected the posts table.
+type UpsertResult struct {
+	Inserted  int            `json:"inserted"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Rejected  []RejectedPost `json:"rejected,omitempty"` // posts the store refused, if it reports them
+
+	// Duplicates counts posts dropped because a later post in the same write
+	// had the same key.
+	Duplicates int `json:"duplicates,omitempty"`
+}
+
+// RejectedPost is a post the store refused to write, with the reason.
+type RejectedPost struct {
+	UserID int    `json:"userId"`
+	ID     int    `json:"id"`
+	Error  string `json:"error"`
+}
+
+// Add accumulates o into r.
+func (r *UpsertResult) Add(o UpsertResult) {
+	r.Inserted += o.Inserted
+	r.Updated += o.Updated
+	r.Unchanged += o.Unchanged
+	r.Duplicates += o.Duplicates
+	r.Rejected = append(r.Rejected, o.Rejected...)
+}
+
+// Run statuses recorded in the ingest_runs table.
+const (
+	RunRunning     = "running"
+	RunSucceeded   = "succeeded"
+	RunNotModified = "not_modified"
+	RunFailed      = "failed"
+)
+
+// Run is one ingestion run of a source.
+type Run struct {
+	ID         int64      `json:"id"`
+	Source     string     `json:"source"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Fetched    int        `json:"fetched"`
+	Inserted   int        `json:"inserted"`
+	Updated    int        `json:"updated"`
+	Unchanged  int        `json:"unchanged"`
+	Rejected   int        `json:"rejected"` // refused by the store
+	Deleted    int        `json:"deleted"`  // tombstoned by reconciliation
+	Error      string     `json:"error,omitempty"`
 }
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requireAdmin only lets requests carrying the admin bearer token through.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tok, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(tok), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAdminIngest starts an ingestion run of ?source= (or of every source)
+// and answers 202 with the started runs without waiting for them to finish.
+func (s *Server) handleAdminIngest(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	runs, err := s.api.IngestOnce(ctx, source)
+	if err != nil && len(runs) == 0 {
+		writeError(w, "ingest not started", err)
+		return
+	}
+	if err != nil {
+		// some sources were busy; the others were started
+		log.Printf("admin ingest: %v", err)
+	}
+
+	if len(runs) == 1 {
+		w.Header().Set("Location", "/runs/"+strconv.FormatInt(runs[0].ID, 10))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"items": runs,
+	})
+}
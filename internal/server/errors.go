@@ -0,0 +1,37 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+)
+
+// writeError maps err to an HTTP status and writes msg and err as plain text.
+// Upstream failures surface as 502/504 so they are not confused with our own
+// faults; a store query running out of time is one of those, a 500.
+func writeError(w http.ResponseWriter, msg string, err error) {
+	status := http.StatusInternalServerError
+	var ue *ingest.UpstreamError
+	switch {
+	case errors.Is(err, ingest.ErrUnknownSource):
+		status = http.StatusNotFound
+	case errors.Is(err, ingest.ErrInvalidQuery):
+		status = http.StatusBadRequest
+	case errors.Is(err, ingest.ErrRunInProgress), errors.Is(err, ingest.ErrPushOnly):
+		status = http.StatusConflict
+	case errors.Is(err, ingest.ErrInvalidPush):
+		status = http.StatusBadRequest
+	case errors.Is(err, ingest.ErrIdempotencyMismatch):
+		status = http.StatusUnprocessableEntity
+	case errors.As(err, &ue):
+		status = http.StatusBadGateway
+		w.Header().Set("X-Upstream-Status", strconv.Itoa(ue.StatusCode))
+	case errors.Is(err, ingest.ErrDecode), errors.Is(err, ingest.ErrTruncated):
+		status = http.StatusBadGateway
+	case errors.Is(err, ingest.ErrTimeout):
+		status = http.StatusGatewayTimeout
+	}
+	http.Error(w, msg+": "+err.Error(), status)
+}
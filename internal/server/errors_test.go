@@ -0,0 +1,36 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"upstream status", fmt.Errorf("fetch: %w", &ingest.UpstreamError{StatusCode: 503}), http.StatusBadGateway},
+		{"upstream body", fmt.Errorf("%w: eof", ingest.ErrDecode), http.StatusBadGateway},
+		{"truncated body", fmt.Errorf("%w: %w", ingest.ErrTruncated, io.ErrUnexpectedEOF), http.StatusBadGateway},
+		{"upstream timeout", fmt.Errorf("%w: %w", ingest.ErrTimeout, context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"store timeout", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusInternalServerError},
+		{"run in progress", ingest.ErrRunInProgress, http.StatusConflict},
+		{"other", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		writeError(rec, "failed", tc.err)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.status)
+		}
+	}
+}
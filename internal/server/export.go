@@ -0,0 +1,191 @@
+package http
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// exportFlushEvery is the number of posts written between flushes, so
+// clients see progress and nothing piles up in buffers.
+const exportFlushEvery = 1000
+
+var csvHeader = []string{"user_id", "id", "title", "body", "ingested_at", "source", "content_hash", "deleted_at"}
+
+// handleExportPosts streams every post matching the filters of GET /posts as
+// NDJSON or CSV, gzip-compressed if the client accepts it. There is no page
+// size: limit is optional and unbounded. Once the first post is written the
+// status is committed, so a failure later aborts the connection rather than
+// ending the body cleanly, and clients can tell the export is truncated.
+func (s *Server) handleExportPosts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	if format != "ndjson" && format != "csv" {
+		http.Error(w, "invalid format, want ndjson or csv", http.StatusBadRequest)
+		return
+	}
+	pq, err := parsePostQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pq.Limit = parseInt(q.Get("limit"), 0)
+
+	out := &exportWriter{w: w, format: format, gzip: acceptsGzip(r), raw: pq.IncludeRaw}
+	err = s.api.ExportPosts(r.Context(), pq, q.Get("cursor"), out.write)
+	if err == nil {
+		err = out.close()
+	}
+	if err == nil {
+		return
+	}
+	if !out.started {
+		writeError(w, "export error", err)
+		return
+	}
+	log.Printf("export: aborted after %d posts: %v", out.rows, err)
+	panic(http.ErrAbortHandler)
+}
+
+// exportWriter encodes posts to the response, writing the headers with the
+// first post (or on close for an empty export).
+type exportWriter struct {
+	w      http.ResponseWriter
+	format string
+	gzip   bool
+	raw    bool // CSV: add a column with the raw upstream object
+
+	started bool
+	rows    int
+	gz      *gzip.Writer
+	json    *json.Encoder
+	csv     *csv.Writer
+}
+
+func (e *exportWriter) start() error {
+	e.started = true
+	h := e.w.Header()
+	if e.format == "csv" {
+		h.Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		h.Set("Content-Type", "application/x-ndjson")
+	}
+	h.Set("Content-Disposition", `attachment; filename="posts.`+e.format+`"`)
+	h.Set("Vary", "Accept-Encoding")
+
+	var body io.Writer = e.w
+	if e.gzip {
+		h.Set("Content-Encoding", "gzip")
+		e.gz = gzip.NewWriter(e.w)
+		body = e.gz
+	}
+	e.w.WriteHeader(http.StatusOK)
+
+	if e.format == "csv" {
+		e.csv = csv.NewWriter(body)
+		if e.raw {
+			return e.csv.Write(slices.Concat(csvHeader, []string{"raw"}))
+		}
+		return e.csv.Write(csvHeader)
+	}
+	e.json = json.NewEncoder(body)
+	return nil
+}
+
+func (e *exportWriter) write(p models.EnrichedPost) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	if err := e.encode(p); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%exportFlushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exportWriter) encode(p models.EnrichedPost) error {
+	if e.json != nil {
+		return e.json.Encode(p)
+	}
+	deletedAt := ""
+	if p.DeletedAt != nil {
+		deletedAt = p.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+	rec := []string{
+		strconv.Itoa(p.UserID), strconv.Itoa(p.ID), p.Title, p.Body,
+		p.IngestedAt.UTC().Format(time.RFC3339Nano), p.Source, p.ContentHash, deletedAt,
+	}
+	if e.raw {
+		rec = append(rec, string(p.Raw))
+	}
+	return e.csv.Write(rec)
+}
+
+// flush pushes everything encoded so far to the client.
+func (e *exportWriter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.gz != nil {
+		if err := e.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	err := http.NewResponseController(e.w).Flush()
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+// close finishes the body; an empty export still gets headers (and the CSV
+// header row).
+func (e *exportWriter) close() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.gz != nil {
+		return e.gz.Close()
+	}
+	return nil
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
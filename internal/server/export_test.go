@@ -0,0 +1,171 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+func exportPosts(n int) []models.EnrichedPost {
+	at := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	posts := make([]models.EnrichedPost, n)
+	for i := range posts {
+		posts[i] = models.EnrichedPost{
+			UserID: 1, ID: i + 1, Title: fmt.Sprintf("t%d", i+1), Body: "a, \"b\"\nc",
+			IngestedAt: at, Source: "s", ContentHash: "h", Raw: json.RawMessage(`{"x":1}`),
+		}
+	}
+	return posts
+}
+
+// exportBody reads the response body, decompressing it if it is gzipped.
+func exportBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = zr
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExport_Formats(t *testing.T) {
+	deleted := time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC)
+	posts := exportPosts(2)
+	posts[1].DeletedAt = &deleted
+	ts := newTestServer(t, &memStore{posts: posts}, nil)
+
+	for _, gz := range []bool{false, true} {
+		header := http.Header{}
+		if gz {
+			header.Set("Accept-Encoding", "gzip")
+		}
+
+		resp := get(t, ts.URL+"/posts/export", header)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Fatalf("ndjson: status %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if got := resp.Header.Get("Content-Encoding") == "gzip"; got != gz {
+			t.Fatalf("ndjson: gzip = %v, want %v", got, gz)
+		}
+		sc := bufio.NewScanner(strings.NewReader(exportBody(t, resp)))
+		var got []models.EnrichedPost
+		for sc.Scan() {
+			var p models.EnrichedPost
+			if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+				t.Fatalf("ndjson line %q: %v", sc.Text(), err)
+			}
+			got = append(got, p)
+		}
+		if len(got) != 2 || got[1].ID != 2 || got[1].DeletedAt == nil || !got[1].DeletedAt.Equal(deleted) {
+			t.Fatalf("ndjson (gzip %v): got %+v", gz, got)
+		}
+
+		resp = get(t, ts.URL+"/posts/export?format=csv&include_raw=true", header)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/csv; charset=utf-8" {
+			t.Fatalf("csv: status %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename="posts.csv"` {
+			t.Fatalf("csv: Content-Disposition %q", cd)
+		}
+		recs, err := csv.NewReader(strings.NewReader(exportBody(t, resp))).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"1", "2", "t2", posts[1].Body, "2025-08-01T10:00:00Z", "s", "h", "2025-08-02T00:00:00Z", `{"x":1}`}
+		if len(recs) != 3 || strings.Join(recs[0], ",") != strings.Join(csvHeader, ",")+",raw" ||
+			strings.Join(recs[2], "|") != strings.Join(want, "|") {
+			t.Fatalf("csv (gzip %v): got %q", gz, recs)
+		}
+	}
+}
+
+func TestExport_Empty(t *testing.T) {
+	ts := newTestServer(t, &memStore{}, nil)
+	resp := get(t, ts.URL+"/posts/export?format=csv", http.Header{"Accept-Encoding": {"gzip"}})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("status %d, Content-Encoding %q", resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+	if body := exportBody(t, resp); body != strings.Join(csvHeader, ",")+"\n" {
+		t.Fatalf("body %q, want only the header row", body)
+	}
+}
+
+func TestExport_ErrorBeforeFirstPost(t *testing.T) {
+	ts := newTestServer(t, &memStore{exportErr: errors.New("db down")}, nil)
+	resp := get(t, ts.URL+"/posts/export", nil)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", resp.StatusCode)
+	}
+	if resp.Header.Get("Content-Disposition") != "" {
+		t.Fatal("an export that never started was sent as an attachment")
+	}
+}
+
+func TestExport_AbortsAfterStart(t *testing.T) {
+	// enough posts for a flush, so the status is on the wire before the
+	// store fails
+	ts := newTestServer(t, &memStore{posts: exportPosts(exportFlushEvery), exportErr: errors.New("db down")}, nil)
+
+	for _, header := range []http.Header{nil, {"Accept-Encoding": {"gzip"}}} {
+		resp := get(t, ts.URL+"/posts/export", header)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d, want 200", resp.StatusCode)
+		}
+		var body io.Reader = resp.Body
+		if header != nil {
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = zr
+		}
+		b, err := io.ReadAll(body)
+		if err == nil {
+			t.Fatalf("a failed export ended cleanly after %d bytes", len(b))
+		}
+		if n := strings.Count(string(b), "\n"); n != exportFlushEvery {
+			t.Fatalf("received %d posts before the abort, want %d", n, exportFlushEvery)
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := map[string]bool{
+		"":                      false,
+		"gzip":                  true,
+		"GZIP":                  true,
+		"deflate, gzip;q=0.5":   true,
+		"br ,  gzip ; q=1":      true,
+		"gzip;q=0":              false,
+		"gzip; q=0, deflate":    false,
+		"x-gzip":                false,
+		"identity, deflate, br": false,
+	}
+	for header, want := range cases {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			r.Header.Set("Accept-Encoding", header)
+		}
+		if got := acceptsGzip(r); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
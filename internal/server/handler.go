@@ -1,48 +1,285 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
 )
 
 func (s *Server) handleGetPosts(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	user := q.Get("userId")
-	limit := parseInt(q.Get("limit"), 50)
-	offset := parseInt(q.Get("offset"), 0)
+	pq, err := parsePostQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	var (
 		items any
-		err   error
+		next  string
 	)
-
-	if user == "" {
-		// no userId provided -> recent with pagination
-		items, err = s.api.QueryRecent(ctx, limit, offset)
-	} else {
-		uid, convErr := strconv.Atoi(user)
-		if convErr != nil {
-			http.Error(w, "invalid userId", http.StatusBadRequest)
+	if q.Has("as_of") {
+		// the dataset as it was at a point in time
+		at, parseErr := time.Parse(time.RFC3339, q.Get("as_of"))
+		if parseErr != nil {
+			http.Error(w, "invalid as_of, want RFC 3339", http.StatusBadRequest)
+			return
+		}
+		if len(pq.UserIDs) > 1 {
+			http.Error(w, "as_of supports a single userId", http.StatusBadRequest)
+			return
+		}
+		if pq.IncludeRaw || len(pq.DocContains) > 0 {
+			// revisions keep the mapped fields only
+			http.Error(w, "as_of does not support include_raw or doc", http.StatusBadRequest)
 			return
 		}
-		items, err = s.api.QueryByUser(ctx, uid)
+		uid := 0
+		if len(pq.UserIDs) == 1 {
+			uid = pq.UserIDs[0]
+		}
+		items, err = s.api.QueryAsOf(ctx, at, uid, pq.Limit, pq.Offset)
+	} else {
+		items, next, err = s.api.QueryPosts(ctx, pq, q.Get("cursor"))
 	}
 	if err != nil {
-		http.Error(w, "query error: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, "query error", err)
+		return
+	}
+
+	resp := map[string]any{
+		"items":  items,
+		"limit":  pq.Limit,
+		"offset": pq.Offset,
+	}
+	if next != "" {
+		resp["next_cursor"] = next
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleSearchPosts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("q") == "" {
+		http.Error(w, "missing q", http.StatusBadRequest)
+		return
+	}
+	if q.Has("sort") || q.Has("cursor") {
+		http.Error(w, "search results are ordered by rank and paged by offset", http.StatusBadRequest)
+		return
+	}
+	pq, err := parsePostQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	hits, err := s.api.SearchPosts(ctx, ingest.SearchQuery{Text: q.Get("q"), PostQuery: pq})
+	if err != nil {
+		writeError(w, "search error", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
-		"items":  items,
-		"limit":  limit,
-		"offset": offset,
+		"items":  hits,
+		"limit":  pq.Limit,
+		"offset": pq.Offset,
+	})
+}
+
+// parsePostQuery reads the filters, sort and paging of a post listing. List
+// parameters take comma-separated values, repeated or not.
+func parsePostQuery(q url.Values) (ingest.PostQuery, error) {
+	pq := ingest.PostQuery{
+		Sources:       queryList(q, "source"),
+		TitleContains: q.Get("title_contains"),
+		Limit:         parseInt(q.Get("limit"), 50),
+		Offset:        parseInt(q.Get("offset"), 0),
+	}
+	pq.IncludeDeleted, _ = strconv.ParseBool(q.Get("include_deleted"))
+	pq.IncludeRaw, _ = strconv.ParseBool(q.Get("include_raw"))
+	if v := q.Get("doc"); v != "" {
+		pq.DocContains = json.RawMessage(v)
+	}
+
+	for _, v := range queryList(q, "userId") {
+		uid, err := strconv.Atoi(v)
+		if err != nil {
+			return pq, errors.New("invalid userId")
+		}
+		pq.UserIDs = append(pq.UserIDs, uid)
+	}
+	for _, v := range queryList(q, "id") {
+		r, err := parseIDRange(v)
+		if err != nil {
+			return pq, fmt.Errorf("invalid id %q, want N or N-M", v)
+		}
+		pq.IDRanges = append(pq.IDRanges, r)
+	}
+	for name, dst := range map[string]*time.Time{
+		"ingested_since": &pq.IngestedSince,
+		"ingested_until": &pq.IngestedUntil,
+	} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return pq, fmt.Errorf("invalid %s, want RFC 3339", name)
+			}
+			*dst = t
+		}
+	}
+	sort, err := ingest.ParseSort(q.Get("sort"))
+	if err != nil {
+		return pq, err
+	}
+	pq.Sort = sort
+	return pq, nil
+}
+
+// parseIDRange parses "N" or "N-M".
+func parseIDRange(s string) (ingest.IDRange, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	from, err := strconv.Atoi(lo)
+	if err != nil {
+		return ingest.IDRange{}, err
+	}
+	if !isRange {
+		return ingest.IDRange{Min: from, Max: from}, nil
+	}
+	to, err := strconv.Atoi(hi)
+	if err != nil {
+		return ingest.IDRange{}, err
+	}
+	return ingest.IDRange{Min: from, Max: to}, nil
+}
+
+// queryList returns the non-empty comma-separated values of every key param.
+func queryList(q url.Values, key string) []string {
+	var out []string
+	for _, v := range q[key] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// handleGetPost serves one post with validators derived from its content
+// hash, source, tombstone and ingestion time, so clients can revalidate with
+// If-None-Match or If-Modified-Since. The ETag is weak because the body also
+// depends on the query, such as include_raw. Tombstoned posts are 404 unless
+// include_deleted is set; the raw upstream object is only included with
+// include_raw.
+func (s *Server) handleGetPost(w http.ResponseWriter, r *http.Request) {
+	uid, err1 := strconv.Atoi(r.PathValue("userId"))
+	id, err2 := strconv.Atoi(r.PathValue("id"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid userId or id", http.StatusBadRequest)
+		return
+	}
+	includeDeleted, _ := strconv.ParseBool(r.URL.Query().Get("include_deleted"))
+	includeRaw, _ := strconv.ParseBool(r.URL.Query().Get("include_raw"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	post, err := s.api.GetPost(ctx, uid, id)
+	if errors.Is(err, ingest.ErrNotFound) || (err == nil && post.DeletedAt != nil && !includeDeleted) {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, "query error", err)
+		return
+	}
+
+	if !includeRaw {
+		post.Raw = nil
+	}
+	body, err := json.Marshal(post)
+	if err != nil {
+		writeError(w, "encode error", err)
+		return
+	}
+	w.Header().Set("ETag", postETag(post))
+	w.Header().Set("Content-Type", "application/json")
+	// ServeContent answers conditional requests and sets Last-Modified
+	http.ServeContent(w, r, "", postModified(post), bytes.NewReader(append(body, '\n')))
+}
+
+// postETag returns a weak ETag over what a post's body is made of: its
+// content hash, its source and whether and when it was tombstoned.
+func postETag(p models.EnrichedPost) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", p.ContentHash, p.Source)
+	if p.DeletedAt != nil {
+		h.Write([]byte(p.DeletedAt.UTC().Format(time.RFC3339Nano)))
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// postModified returns when a post last changed: when it was ingested, or
+// tombstoned if that came later.
+func postModified(p models.EnrichedPost) time.Time {
+	if p.DeletedAt != nil && p.DeletedAt.After(p.IngestedAt) {
+		return *p.DeletedAt
+	}
+	return p.IngestedAt
+}
+
+func (s *Server) handleGetPostVersions(w http.ResponseWriter, r *http.Request) {
+	uid, err1 := strconv.Atoi(r.PathValue("userId"))
+	id, err2 := strconv.Atoi(r.PathValue("id"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid userId or id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	versions, err := s.api.ListVersions(ctx, uid, id)
+	if err != nil {
+		writeError(w, "query error", err)
+		return
+	}
+	if len(versions) == 0 {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"items": versions,
+	})
+}
+
+func (s *Server) handleGetSources(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"items": s.api.Sources(),
 	})
 }
 
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+func TestGetPost_Validators(t *testing.T) {
+	ingested := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	deleted := ingested.Add(time.Hour)
+	post := models.EnrichedPost{UserID: 1, ID: 1, Title: "t", Source: "a", ContentHash: "h", IngestedAt: ingested}
+	store := &memStore{posts: []models.EnrichedPost{post}}
+	ts := newTestServer(t, store, nil)
+	url := ts.URL + "/posts/1/1?include_deleted=true"
+
+	resp := get(t, url, nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("status %d, ETag %q", resp.StatusCode, etag)
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != ingested.Format(http.TimeFormat) {
+		t.Fatalf("Last-Modified = %q, want the ingestion time", lm)
+	}
+	if resp := get(t, url, http.Header{"If-None-Match": {etag}}); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("revalidation: status %d, want 304", resp.StatusCode)
+	}
+
+	// a move to another source or a tombstone changes the representation
+	// without changing the content hash
+	for name, change := range map[string]func(*models.EnrichedPost){
+		"source":    func(p *models.EnrichedPost) { p.Source = "b" },
+		"tombstone": func(p *models.EnrichedPost) { p.DeletedAt = &deleted },
+	} {
+		p := post
+		change(&p)
+		store.posts[0] = p
+		resp := get(t, url, http.Header{"If-None-Match": {etag}})
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+			t.Errorf("%s: status %d, ETag %q unchanged", name, resp.StatusCode, resp.Header.Get("ETag"))
+		}
+		if name == "tombstone" && resp.Header.Get("Last-Modified") != deleted.Format(http.TimeFormat) {
+			t.Errorf("tombstone: Last-Modified = %q, want the deletion time", resp.Header.Get("Last-Modified"))
+		}
+	}
+}
@@ -8,12 +8,20 @@ import (
 )
 
 type Server struct {
-	api *api.API
-	mux *http.ServeMux
+	api         *api.API
+	mux         *http.ServeMux
+	adminToken  string                // bearer token for /admin routes; empty disables them
+	pushSources map[string]PushSource // sources accepting POST /ingest/{source}
 }
 
-func New(a *api.API) *Server {
-	s := &Server{api: a, mux: http.NewServeMux()}
+// PushSource authenticates and bounds deliveries to a push source.
+type PushSource struct {
+	Secret       []byte // HMAC-SHA256 key deliveries are signed with
+	MaxBodyBytes int64
+}
+
+func New(a *api.API, adminToken string, pushSources map[string]PushSource) *Server {
+	s := &Server{api: a, mux: http.NewServeMux(), adminToken: adminToken, pushSources: pushSources}
 	s.routes()
 	return s
 }
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/renix-codex/ingestor/internal/api"
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// memStore is an in-memory ingest.StorePort for handler tests; methods a
+// test does not need panic through the nil embedded interface.
+type memStore struct {
+	ingest.StorePort
+	posts     []models.EnrichedPost
+	exportErr error // returned by ExportPosts after every post was handed over
+}
+
+func (m *memStore) GetPost(ctx context.Context, key models.PostKey) (models.EnrichedPost, error) {
+	for _, p := range m.posts {
+		if p.UserID == key.UserID && p.ID == key.ID {
+			return p, nil
+		}
+	}
+	return models.EnrichedPost{}, ingest.ErrNotFound
+}
+
+func (m *memStore) ExportPosts(ctx context.Context, q ingest.PostQuery, fn func(models.EnrichedPost) error) error {
+	for _, p := range m.posts {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return m.exportErr
+}
+
+func newTestServer(t *testing.T, store ingest.StorePort, pushSources map[string]PushSource) *httptest.Server {
+	t.Helper()
+	svc := ingest.New(store, nil, "", func() time.Time { return testNow })
+	t.Cleanup(svc.Close)
+	for name := range pushSources {
+		if err := svc.AddSource(name, nil, ingest.SourceOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := httptest.NewServer(New(api.New(svc), "", pushSources).mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+var testNow = time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+func get(t *testing.T, url string, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultTransport.RoundTrip(req) // no transparent gzip
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+)
+
+const (
+	// signatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body
+	// under the source's secret.
+	signatureHeader = "X-Signature"
+	// idempotencyHeader names a delivery so retries are not ingested twice.
+	idempotencyHeader = "Idempotency-Key"
+	maxIdempotencyKey = 255
+)
+
+// handlePush ingests a JSON array or NDJSON stream of posts delivered to a
+// push source and answers with the finished run. The body is read in full,
+// up to the source's limit, because its signature must be checked before
+// anything is stored.
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("source")
+	src, ok := s.pushSources[name]
+	if !ok {
+		http.Error(w, "unknown push source", http.StatusNotFound)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if src.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, src.MaxBodyBytes)
+	}
+	raw, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "body exceeds "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "reading body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validSignature(src.Secret, raw, r.Header.Get(signatureHeader)) {
+		http.Error(w, "invalid or missing "+signatureHeader, http.StatusUnauthorized)
+		return
+	}
+
+	key := r.Header.Get(idempotencyHeader)
+	if len(key) > maxIdempotencyKey {
+		http.Error(w, idempotencyHeader+" too long", http.StatusBadRequest)
+		return
+	}
+	posts, err := ingest.DecodePosts(raw)
+	if err != nil {
+		writeError(w, "push rejected", err)
+		return
+	}
+	sum := sha256.Sum256(raw)
+
+	run, replayed, err := s.api.Push(r.Context(), ingest.PushRequest{
+		Source:         name,
+		Posts:          posts,
+		IdempotencyKey: key,
+		RequestHash:    hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		writeError(w, "push failed", err)
+		return
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.Header().Set("Location", "/runs/"+strconv.FormatInt(run.ID, 10))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(run)
+}
+
+// validSignature checks a "sha256=<hex>" signature of body.
+func validSignature(secret, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/renix-codex/ingestor/internal/ingest"
+	"github.com/renix-codex/ingestor/internal/models"
+)
+
+// pushStore records runs, idempotency keys and the number of upserted posts.
+type pushStore struct {
+	memStore
+	runs     []models.Run
+	keys     map[string]ingest.IdempotencyRecord
+	upserted int
+}
+
+func (p *pushStore) Upsert(ctx context.Context, items []models.EnrichedPost) (models.UpsertResult, error) {
+	p.upserted += len(items)
+	return models.UpsertResult{Inserted: len(items)}, nil
+}
+
+func (p *pushStore) CreateRun(ctx context.Context, run models.Run) (int64, error) {
+	run.ID = int64(len(p.runs) + 1)
+	p.runs = append(p.runs, run)
+	return run.ID, nil
+}
+
+func (p *pushStore) FinishRun(ctx context.Context, run models.Run) error {
+	p.runs[run.ID-1] = run
+	return nil
+}
+
+func (p *pushStore) GetRun(ctx context.Context, id int64) (models.Run, error) {
+	if id < 1 || int(id) > len(p.runs) {
+		return models.Run{}, ingest.ErrNotFound
+	}
+	return p.runs[id-1], nil
+}
+
+func (p *pushStore) LookupIdempotencyKey(ctx context.Context, source, key string) (ingest.IdempotencyRecord, error) {
+	rec, ok := p.keys[source+"/"+key]
+	if !ok {
+		return ingest.IdempotencyRecord{}, ingest.ErrNotFound
+	}
+	return rec, nil
+}
+
+func (p *pushStore) ClaimIdempotencyKey(ctx context.Context, rec ingest.IdempotencyRecord) (ingest.IdempotencyRecord, error) {
+	p.keys[rec.Source+"/"+rec.Key] = rec
+	return rec, nil
+}
+
+var pushSecret = []byte("s3cret")
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, pushSecret)
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newPushServer(t *testing.T, store *pushStore) string {
+	t.Helper()
+	ts := newTestServer(t, store, map[string]PushSource{"feed": {Secret: pushSecret, MaxBodyBytes: 64}})
+	return ts.URL + "/ingest/feed"
+}
+
+func post(t *testing.T, url, body string, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`[]`)
+	good := sign(string(body))
+	cases := map[string]bool{
+		good:                                 true,
+		strings.ToUpper(good[:7]) + good[7:]: false, // prefix is case-sensitive
+		strings.TrimPrefix(good, "sha256="):  false,
+		"sha256=" + strings.Repeat("0", 64):  false,
+		"sha256=zz":                          false,
+		"":                                   false,
+	}
+	for header, want := range cases {
+		if got := validSignature(pushSecret, body, header); got != want {
+			t.Errorf("validSignature(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestPush_RejectsBadSignature(t *testing.T) {
+	store := &pushStore{keys: map[string]ingest.IdempotencyRecord{}}
+	url := newPushServer(t, store)
+	body := `[{"userId":1,"id":1}]`
+
+	for name, sig := range map[string]string{"missing": "", "wrong": sign(body + " ")} {
+		resp := post(t, url, body, http.Header{signatureHeader: {sig}})
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s signature: status %d, want 401", name, resp.StatusCode)
+		}
+	}
+	if len(store.runs) != 0 || store.upserted != 0 {
+		t.Fatalf("unsigned deliveries were ingested: %d runs, %d posts", len(store.runs), store.upserted)
+	}
+}
+
+func TestPush_BodyLimit(t *testing.T) {
+	store := &pushStore{keys: map[string]ingest.IdempotencyRecord{}}
+	url := newPushServer(t, store)
+	body := `[` + strings.Repeat(`{"userId":1,"id":1},`, 4) + `{"userId":1,"id":2}]` // over 64 bytes
+
+	resp := post(t, url, body, http.Header{signatureHeader: {sign(body)}})
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want 413", resp.StatusCode)
+	}
+	if len(store.runs) != 0 {
+		t.Fatalf("an oversized delivery started %d runs", len(store.runs))
+	}
+}
+
+func TestPush_IdempotentReplay(t *testing.T) {
+	store := &pushStore{keys: map[string]ingest.IdempotencyRecord{}}
+	url := newPushServer(t, store)
+	body := `[{"userId":1,"id":1}]`
+	header := http.Header{signatureHeader: {sign(body)}, idempotencyHeader: {"k1"}}
+
+	first := post(t, url, body, header)
+	var run models.Run
+	if err := json.NewDecoder(first.Body).Decode(&run); err != nil || first.StatusCode != http.StatusOK {
+		t.Fatalf("first delivery: status %d, %v", first.StatusCode, err)
+	}
+	if run.Status != models.RunSucceeded || first.Header.Get("Location") != "/runs/1" {
+		t.Fatalf("first delivery: run %+v, Location %q", run, first.Header.Get("Location"))
+	}
+	if first.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatal("first delivery marked as replayed")
+	}
+
+	again := post(t, url, body, header)
+	var replayed models.Run
+	if err := json.NewDecoder(again.Body).Decode(&replayed); err != nil || again.StatusCode != http.StatusOK {
+		t.Fatalf("retry: status %d, %v", again.StatusCode, err)
+	}
+	if again.Header.Get("Idempotent-Replayed") != "true" || replayed.ID != run.ID {
+		t.Fatalf("retry: replayed %q, run %d, want run %d", again.Header.Get("Idempotent-Replayed"), replayed.ID, run.ID)
+	}
+	if len(store.runs) != 1 || store.upserted != 1 {
+		t.Fatalf("the retry was ingested again: %d runs, %d posts", len(store.runs), store.upserted)
+	}
+
+	// the same key for another body is refused
+	other := `[{"userId":1,"id":2}]`
+	resp := post(t, url, other, http.Header{signatureHeader: {sign(other)}, idempotencyHeader: {"k1"}})
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key: status %d, want 422", resp.StatusCode)
+	}
+}
@@ -16,4 +16,19 @@ func (s *Server) routes() {
 	})
 
 	s.mux.HandleFunc("GET /posts", s.handleGetPosts)
+	s.mux.HandleFunc("GET /posts/search", s.handleSearchPosts)
+	s.mux.HandleFunc("GET /posts/export", s.handleExportPosts)
+	s.mux.HandleFunc("GET /posts/{userId}/{id}", s.handleGetPost)
+	s.mux.HandleFunc("GET /posts/{userId}/{id}/versions", s.handleGetPostVersions)
+	s.mux.HandleFunc("GET /sources", s.handleGetSources)
+	s.mux.HandleFunc("GET /runs", s.handleListRuns)
+	s.mux.HandleFunc("GET /runs/{id}", s.handleGetRun)
+
+	if len(s.pushSources) > 0 {
+		s.mux.HandleFunc("POST /ingest/{source}", s.handlePush)
+	}
+
+	if s.adminToken != "" {
+		s.mux.HandleFunc("POST /admin/ingest", s.requireAdmin(s.handleAdminIngest))
+	}
 }
@@ -2,7 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	nethttp "net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/renix-codex/ingestor/internal/api"
@@ -14,34 +23,367 @@ import (
 
 func main() {
 	cfg := config.FromEnv()
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, cfg, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
 
 	// adapters
-	pg, err := store.New(ctx, cfg.BuildDSN())
+	connect := store.New
+	if !cfg.MigrateOnStart {
+		connect = store.Connect
+	}
+	pg, err := connect(ctx, cfg.BuildDSN())
 	if err != nil {
 		log.Fatalf("postgres init: %v", err)
 	}
-	col := ingest.NewHTTPCollector(cfg.SourceURL, cfg.HTTPTimeout)
+	defer pg.Close()
+	switch mode := store.UpsertMode(cfg.PGUpsertMode); mode {
+	case store.UpsertAtomic, store.UpsertPerRow:
+		pg.UpsertMode = mode
+	default:
+		log.Fatalf("config: unknown PG_UPSERT_MODE %q", mode)
+	}
+	pg.BulkThreshold = cfg.PGBulkThreshold
+	pg.BulkChunkSize = cfg.PGBulkChunkSize
+	sources, err := cfg.LoadSources()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	pg.DefaultSearchLanguage = cfg.SearchLanguage
+	pg.SearchLanguages = map[string]string{}
+	for _, src := range sources {
+		pg.SearchLanguages[src.Name] = src.SearchLanguage
+	}
+	if err := pg.CheckSearchLanguages(ctx); err != nil {
+		log.Fatalf("config: %v", err)
+	}
 
 	// service
-	svc := ingest.New(pg, col, cfg.SourceName, time.Now)
+	svc := ingest.New(pg, nil, "", time.Now)
+	defer svc.Close()
+	var (
+		pulled      []string // sources with a schedule, in registry order
+		schedules   []ingest.SchedulerConfig
+		pushSources = map[string]http.PushSource{}
+	)
+	for _, src := range sources {
+		opts := ingest.SourceOptions{
+			Timeout:           time.Duration(*src.Timeout),
+			Reconcile:         *src.Reconcile,
+			MaxDeleteFraction: src.MaxDeleteFraction,
+		}
+		if src.Type == "push" {
+			secret, err := config.ReadSecret(src.Push.HMACSecretFile)
+			if err != nil {
+				log.Fatalf("source %s: push secret: %v", src.Name, err)
+			}
+			if secret == "" {
+				log.Fatalf("source %s: push secret: %s is empty", src.Name, src.Push.HMACSecretFile)
+			}
+			pushSources[src.Name] = http.PushSource{Secret: []byte(secret), MaxBodyBytes: src.Push.MaxBodyBytes}
+			if err := svc.AddSource(src.Name, nil, opts); err != nil {
+				log.Fatalf("source %s: %v", src.Name, err)
+			}
+			continue
+		}
+
+		col, err := newCollector(src, pg)
+		if err != nil {
+			log.Fatalf("source %s: collector: %v", src.Name, err)
+		}
+		if err := svc.AddSource(src.Name, col, opts); err != nil {
+			log.Fatalf("source %s: %v", src.Name, err)
+		}
+		sc, err := schedulerConfig(src)
+		if err != nil {
+			log.Fatalf("source %s: %v", src.Name, err)
+		}
+		pulled = append(pulled, src.Name)
+		schedules = append(schedules, sc)
+	}
+
+	// runs left running by a process that stopped are marked failed
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reapRuns(ctx, svc)
+	}()
 
 	// api facade
 	app := api.New(svc)
 
-	// optional: one-shot ingest at startup via API (not directly via svc)
-	ingCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	if n, err := app.IngestOnce(ingCtx); err != nil {
-		log.Printf("ingest failed: %v", err)
-	} else {
-		log.Printf("ingested %d records", n)
+	// periodic ingest via API (not directly via svc), one scheduler per source
+	for i, name := range pulled {
+		sched := ingest.NewScheduler(name, func(ctx context.Context) error {
+			res, err := app.IngestSource(ctx, name)
+			if errors.Is(err, ingest.ErrRunInProgress) {
+				log.Printf("source %s: %v, skipping", name, err)
+				return nil
+			}
+			if err == nil {
+				log.Printf("source %s: %d inserted, %d updated, %d unchanged, %d rejected",
+					name, res.Inserted, res.Updated, res.Unchanged, len(res.Rejected))
+			}
+			return err
+		}, schedules[i])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = sched.Run(ctx)
+		}()
+	}
+
+	var adminToken string
+	if cfg.AdminTokenFile != "" {
+		if adminToken, err = config.ReadSecret(cfg.AdminTokenFile); err != nil {
+			log.Fatalf("admin token: %v", err)
+		}
+		if adminToken == "" {
+			log.Fatalf("admin token: %s is empty", cfg.AdminTokenFile)
+		}
 	}
-	cancel()
 
 	// http server uses the api layer
-	s := http.New(app)
+	s := http.New(app, adminToken, pushSources)
 	log.Printf("listening on %s", cfg.ListenAddr)
-	if err := s.ListenAndServe(context.Background(), cfg.ListenAddr); err != nil {
+	if err := s.ListenAndServe(ctx, cfg.ListenAddr); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
 		log.Fatal(err)
 	}
+	wg.Wait()
+}
+
+// reapRuns marks abandoned runs as failed at startup and then every minute,
+// until ctx is done.
+func reapRuns(ctx context.Context, svc *ingest.Service) {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		if n, err := svc.ReapAbandonedRuns(ctx); err != nil {
+			log.Printf("reaping abandoned runs: %v", err)
+		} else if n > 0 {
+			log.Printf("marked %d abandoned runs as failed", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// schedulerConfig translates a source's schedule settings.
+func schedulerConfig(src config.Source) (ingest.SchedulerConfig, error) {
+	sched, err := ingest.ParseSchedule(src.Schedule)
+	if err != nil {
+		return ingest.SchedulerConfig{}, err
+	}
+	return ingest.SchedulerConfig{
+		Schedule:   sched,
+		Jitter:     time.Duration(*src.Jitter),
+		Timeout:    time.Duration(*src.Timeout),
+		RunOnStart: *src.RunOnStart,
+	}, nil
+}
+
+// newCollector builds the upstream collector described by src.
+func newCollector(src config.Source, validators ingest.ValidatorStore) (ingest.CollectorPort, error) {
+	switch src.Type {
+	case "file":
+		c := ingest.NewFileCollector(src.File.Path)
+		c.FileDecoder = fileDecoder(src)
+		return c, nil
+	case "dir":
+		c := ingest.NewDirCollector(src.File.Path)
+		c.Pattern = src.File.Pattern
+		c.DoneDir = src.File.DoneDir
+		c.FailedDir = src.File.FailedDir
+		c.MinAge = time.Duration(src.File.MinAge)
+		c.FileDecoder = fileDecoder(src)
+		return c, nil
+	}
+
+	timeout := time.Duration(src.HTTPTimeout)
+	auth, err := newAuthenticator(src.Auth, timeout)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := fieldMapping(src.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var col ingest.CollectorPort
+	switch src.Type {
+	case "paginated":
+		p := src.Pagination
+		c := ingest.NewPaginatedCollector(src.URL, timeout, ingest.Pagination{
+			Mode:       ingest.PaginationMode(p.Mode),
+			PageSize:   p.PageSize,
+			MaxPages:   p.MaxPages,
+			PageParam:  p.PageParam,
+			SizeParam:  p.SizeParam,
+			ItemsPath:  p.ItemsPath,
+			CursorPath: p.CursorPath,
+		})
+		c.MaxBodyBytes = src.MaxBodyBytes
+		c.Auth = auth
+		c.Mapping = mapping
+		col = c
+	default:
+		c := ingest.NewHTTPCollector(src.URL, timeout)
+		c.MaxBodyBytes = src.MaxBodyBytes
+		c.BatchSize = src.BatchSize
+		c.Auth = auth
+		c.Mapping = mapping
+		if *src.ConditionalGET {
+			c.Source = src.Name
+			c.Validators = validators
+		}
+		col = c
+	}
+
+	if r := src.Retry; r.MaxAttempts > 1 {
+		col = ingest.NewRetryCollector(col, ingest.RetryPolicy{
+			MaxAttempts: r.MaxAttempts,
+			BaseDelay:   time.Duration(r.BaseDelay),
+			MaxDelay:    time.Duration(r.MaxDelay),
+			MaxElapsed:  time.Duration(r.MaxElapsed),
+		})
+	}
+	return col, nil
+}
+
+// fieldMapping builds the field mapping described by m, or returns nil if
+// the upstream serves posts as is.
+func fieldMapping(m config.MappingConfig) (*ingest.FieldMapping, error) {
+	if m.Root == "" && len(m.Fields) == 0 {
+		return nil, nil
+	}
+	fields := make(map[string]ingest.FieldSpec, len(m.Fields))
+	for name, f := range m.Fields {
+		fields[name] = ingest.FieldSpec{Path: f.Path, Default: f.Default}
+	}
+	return ingest.NewFieldMapping(m.Root, fields)
+}
+
+// fileDecoder describes how a file or dir source's files are decoded.
+func fileDecoder(src config.Source) ingest.FileDecoder {
+	return ingest.FileDecoder{
+		Format:    ingest.FileFormat(src.File.Format),
+		Columns:   src.File.Columns,
+		BatchSize: src.BatchSize,
+	}
+}
+
+// newAuthenticator builds the upstream authenticator, reading secrets from
+// their files. It returns nil for unauthenticated sources.
+func newAuthenticator(a config.AuthConfig, timeout time.Duration) (ingest.Authenticator, error) {
+	switch a.Type {
+	case "":
+		return nil, nil
+	case "bearer":
+		tok, err := config.ReadSecret(a.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("bearer token: %w", err)
+		}
+		return ingest.BearerToken{Token: tok}, nil
+	case "basic":
+		pw, err := config.ReadSecret(a.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth password: %w", err)
+		}
+		return ingest.BasicAuth{Username: a.Username, Password: pw}, nil
+	case "apikey":
+		key, err := config.ReadSecret(a.APIKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("api key: %w", err)
+		}
+		return ingest.APIKey{Name: a.APIKeyName, Value: key, In: ingest.APIKeyLocation(a.APIKeyIn)}, nil
+	case "oauth2":
+		secret, err := config.ReadSecret(a.ClientSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 client secret: %w", err)
+		}
+		return &ingest.OAuth2ClientCredentials{
+			TokenURL:     a.TokenURL,
+			ClientID:     a.ClientID,
+			ClientSecret: secret,
+			Scopes:       a.Scopes,
+			Client:       &nethttp.Client{Timeout: timeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth type %q", a.Type)
+	}
+}
+
+const migrateUsage = `usage: ingestor migrate <command>
+
+commands:
+  up          apply all pending migrations
+  list        list migrations and whether they are applied
+  down [n]    roll back the n most recent migrations (default 1)`
+
+// runMigrate implements the "migrate" command.
+func runMigrate(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", migrateUsage)
+	}
+
+	pg, err := store.Connect(ctx, cfg.BuildDSN())
+	if err != nil {
+		return err
+	}
+	defer pg.Close()
+	m, err := pg.Migrator()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		rolledBack, err := m.Down(ctx, steps)
+		for _, mig := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "list":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, st := range status {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+	}
 }
@@ -0,0 +1,7 @@
+-- HTTP cache validators of the last stored response, per source
+CREATE TABLE IF NOT EXISTS source_validators (
+  source         TEXT  PRIMARY KEY,
+  etag           TEXT  NOT NULL,
+  last_modified  TEXT  NOT NULL,
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
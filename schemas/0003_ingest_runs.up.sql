@@ -0,0 +1,16 @@
+-- one row per ingestion run of a source
+CREATE TABLE IF NOT EXISTS ingest_runs (
+  id           BIGSERIAL PRIMARY KEY,
+  source       TEXT   NOT NULL,
+  status       TEXT   NOT NULL,          -- running | succeeded | not_modified | failed
+  started_at   TIMESTAMPTZ NOT NULL,
+  finished_at  TIMESTAMPTZ,
+  fetched      INT    NOT NULL DEFAULT 0,
+  inserted     INT    NOT NULL DEFAULT 0,
+  updated      INT    NOT NULL DEFAULT 0,
+  unchanged    INT    NOT NULL DEFAULT 0,
+  error        TEXT   NOT NULL DEFAULT ''
+);
+
+-- history per source, newest first
+CREATE INDEX IF NOT EXISTS idx_ingest_runs_source_started ON ingest_runs(source, started_at DESC);
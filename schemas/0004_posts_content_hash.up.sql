@@ -0,0 +1,2 @@
+-- hex SHA-256 of the upstream fields, see ingest.ContentHash
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_hash TEXT;
@@ -0,0 +1,21 @@
+-- every distinct revision of a post; valid_to is NULL for the current one
+CREATE TABLE IF NOT EXISTS post_versions (
+  user_id      INT    NOT NULL,
+  id           INT    NOT NULL,
+  title        TEXT   NOT NULL,
+  body         TEXT   NOT NULL,
+  source       TEXT   NOT NULL,
+  content_hash TEXT   NOT NULL,
+  valid_from   TIMESTAMPTZ NOT NULL,   -- ingested_at of the revision
+  valid_to     TIMESTAMPTZ,            -- valid_from of the next revision
+  PRIMARY KEY (user_id, id, valid_from)
+);
+
+-- "as of" lookups
+CREATE INDEX IF NOT EXISTS idx_post_versions_validity ON post_versions(valid_from, valid_to);
+
+-- seed history with the current row of posts ingested before versioning
+INSERT INTO post_versions (user_id, id, title, body, source, content_hash, valid_from)
+SELECT p.user_id, p.id, p.title, p.body, p.source, COALESCE(p.content_hash, ''), p.ingested_at
+FROM posts p
+WHERE NOT EXISTS (SELECT 1 FROM post_versions v WHERE v.user_id = p.user_id AND v.id = p.id);
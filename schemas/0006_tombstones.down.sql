@@ -0,0 +1,3 @@
+ALTER TABLE ingest_runs DROP COLUMN IF EXISTS deleted;
+DROP INDEX IF EXISTS idx_posts_source_live;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,8 @@
+-- set when the post disappeared from a reconciled source
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- live posts per source, for reconciliation
+CREATE INDEX IF NOT EXISTS idx_posts_source_live ON posts(source) WHERE deleted_at IS NULL;
+
+-- posts tombstoned by a run
+ALTER TABLE ingest_runs ADD COLUMN IF NOT EXISTS deleted INT NOT NULL DEFAULT 0;
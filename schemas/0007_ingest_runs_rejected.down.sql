@@ -0,0 +1 @@
+ALTER TABLE ingest_runs DROP COLUMN IF EXISTS rejected;
@@ -0,0 +1,2 @@
+-- posts the store refused during a run (per_row upsert mode)
+ALTER TABLE ingest_runs ADD COLUMN IF NOT EXISTS rejected INT NOT NULL DEFAULT 0;
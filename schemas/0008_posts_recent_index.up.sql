@@ -0,0 +1,2 @@
+-- keyset pagination of GET /posts: (ingested_at, id, user_id) descending
+CREATE INDEX IF NOT EXISTS idx_posts_recent ON posts(ingested_at DESC, id DESC, user_id DESC);
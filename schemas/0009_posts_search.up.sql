@@ -0,0 +1,11 @@
+-- text search configuration of the post's source; set on write
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_lang REGCONFIG NOT NULL DEFAULT 'english';
+
+-- full-text search document: title ranks above body
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+  GENERATED ALWAYS AS (
+    setweight(to_tsvector(search_lang, title), 'A') ||
+    setweight(to_tsvector(search_lang, body), 'B')
+  ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (search_vector);
@@ -0,0 +1,12 @@
+-- idempotency keys of pushed deliveries and the run that processed them
+CREATE TABLE IF NOT EXISTS ingest_idempotency_keys (
+  source       TEXT   NOT NULL,
+  key          TEXT   NOT NULL,
+  request_hash TEXT   NOT NULL,
+  run_id       BIGINT NOT NULL REFERENCES ingest_runs(id) ON DELETE CASCADE,
+  created_at   TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (source, key)
+);
+
+-- expiry
+CREATE INDEX IF NOT EXISTS idx_ingest_idempotency_keys_created ON ingest_idempotency_keys(created_at);
@@ -0,0 +1,13 @@
+// Package schemas holds the versioned SQL migrations of the posts database.
+//
+// Files are named NNNN_description.up.sql and NNNN_description.down.sql and
+// applied in version order by (*store.Migrator).Up, see store.NewMigrator.
+// Migrations already applied in a deployment must not be edited; add a new
+// version instead. The early versions use IF NOT EXISTS so databases created
+// before migrations existed converge on the same schema.
+package schemas
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS